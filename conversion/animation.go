@@ -0,0 +1,166 @@
+package conversion
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"sync"
+	"time"
+)
+
+// TransformGIF applies a conversion to every frame of an animated GIF. Frames are
+// composited onto the full canvas first so partial frames and disposal methods
+// render the same as in the original, and the delays, disposal methods and loop
+// count are carried over to the result.
+func TransformGIF(ctx context.Context, t Transformer, g *gif.GIF, conversionName string, options ...Option) (*gif.GIF, error) {
+	options = append(options[:len(options):len(options)], WithSharedCrop())
+
+	// The timeout of the conversion bounds all frames together
+	var timeout time.Duration
+	if timeouts, ok := t.(interface{ ConversionTimeout(string) time.Duration }); ok {
+		timeout = timeouts.ConversionTimeout(conversionName)
+	}
+	runCtx, cancel, timeout := withConversionDeadline(ctx, timeout)
+	defer cancel()
+
+	result, err := TransformGIFFunc(runCtx, g, func(ctx context.Context, frame image.Image) (image.Image, error) {
+		return t.Transform(ctx, frame, conversionName, options...)
+	})
+	if err != nil {
+		return nil, timeoutError(ctx, runCtx, conversionName, timeout, err)
+	}
+
+	return result, nil
+}
+
+// TransformGIFFunc is like TransformGIF but applies fn to every composited frame. Resizes
+// done by fn should use WithSharedCrop so every frame gets the same crop window.
+func TransformGIFFunc(ctx context.Context, g *gif.GIF, fn func(ctx context.Context, frame image.Image) (image.Image, error)) (*gif.GIF, error) {
+	if len(g.Image) == 0 {
+		return nil, fmt.Errorf("gif has no frames")
+	}
+
+	canvasRect := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if canvasRect.Empty() {
+		canvasRect = g.Image[0].Bounds()
+	}
+
+	canvas := image.NewRGBA(canvasRect)
+	result := &gif.GIF{
+		Image:           make([]*image.Paletted, 0, len(g.Image)),
+		Delay:           make([]int, 0, len(g.Image)),
+		Disposal:        make([]byte, 0, len(g.Image)),
+		LoopCount:       g.LoopCount,
+		BackgroundIndex: g.BackgroundIndex,
+	}
+
+	for i, frame := range g.Image {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(canvasRect)
+			draw.Draw(previous, canvasRect, canvas, canvasRect.Min, draw.Src)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		snapshot := image.NewRGBA(canvasRect)
+		draw.Draw(snapshot, canvasRect, canvas, canvasRect.Min, draw.Src)
+
+		transformed, err := fn(ctx, snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transform frame %d: %w", i, err)
+		}
+
+		result.Image = append(result.Image, quantize(transformed, frame.Palette))
+		result.Disposal = append(result.Disposal, disposal)
+		if i < len(g.Delay) {
+			result.Delay = append(result.Delay, g.Delay[i])
+		} else {
+			result.Delay = append(result.Delay, 0)
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	bounds := result.Image[0].Bounds()
+	result.Config = image.Config{
+		ColorModel: result.Image[0].Palette,
+		Width:      bounds.Dx(),
+		Height:     bounds.Dy(),
+	}
+
+	return result, nil
+}
+
+// sharedCrop is the crop window chosen for the first image resized with a WithSharedCrop option
+type sharedCrop struct {
+	rect   image.Rectangle
+	chosen bool
+	mu     sync.Mutex
+}
+
+// WithSharedCrop makes every image resized with this option use the fill or smart crop
+// window chosen for the first one, so all frames of an animation are cropped identically
+// and SmartCropDebug is only called once. Create one option per animation.
+func WithSharedCrop() Option {
+	crop := &sharedCrop{}
+	return func(o *Options) {
+		o.sharedCrop = crop
+	}
+}
+
+// cropWindow returns the crop window, calling find unless a shared window was already
+// chosen. chosen reports whether find was called.
+func (o *Options) cropWindow(find func() (image.Rectangle, error)) (rect image.Rectangle, chosen bool, err error) {
+	if o.sharedCrop == nil {
+		rect, err = find()
+		return rect, err == nil, err
+	}
+
+	o.sharedCrop.mu.Lock()
+	defer o.sharedCrop.mu.Unlock()
+
+	if o.sharedCrop.chosen {
+		return o.sharedCrop.rect, false, nil
+	}
+
+	rect, err = find()
+	if err != nil {
+		return rect, false, err
+	}
+	o.sharedCrop.rect, o.sharedCrop.chosen = rect, true
+
+	return rect, true, nil
+}
+
+// quantize converts a transformed frame back to a paletted image, reusing the
+// palette of the source frame when there is one.
+func quantize(img image.Image, p color.Palette) *image.Paletted {
+	if len(p) == 0 {
+		p = palette.Plan9
+	}
+
+	bounds := img.Bounds()
+	paletted := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), p)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), img, bounds.Min)
+
+	return paletted
+}
@@ -0,0 +1,47 @@
+package conversion
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/disintegration/imaging"
+)
+
+// backgroundColor returns the configured background, falling back to
+// transparent for formats with alpha support and white otherwise.
+func backgroundColor(opts *Options) color.NRGBA {
+	if opts.Background != "" {
+		if c, err := parseHexColor(opts.Background); err == nil {
+			return c
+		}
+	}
+
+	if FormatSupportsAlpha(opts.Format) {
+		return color.NRGBA{}
+	}
+	return color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+}
+
+// pad fits the image inside the box and letterboxes it to the exact size with
+// the background color, positioned by the gravity.
+func pad(img image.Image, width, height int, opts *Options) image.Image {
+	fitted := imaging.Fit(img, width, height, imaging.Lanczos)
+	canvas := imaging.New(width, height, backgroundColor(opts))
+
+	pos := anchorPoint(canvas.Bounds(), fitted.Bounds().Size(), gravityAnchor(opts.Gravity), 0, 0)
+	return imaging.Overlay(canvas, fitted, pos, 1)
+}
+
+// flatten composites the image onto the background color so formats without
+// transparency do not end up with black backgrounds.
+func flatten(img image.Image, opts *Options) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+
+	bg := backgroundColor(opts)
+	bg.A = 0xff
+
+	bounds := img.Bounds()
+	return imaging.Overlay(imaging.New(bounds.Dx(), bounds.Dy(), bg), img, image.Pt(0, 0), 1)
+}
@@ -11,6 +11,9 @@ type Transformer interface {
 	Transform(ctx context.Context, img image.Image, conversionName string, options ...Option) (image.Image, error)
 
 
+	TransformWithOptions(ctx context.Context, img image.Image, conversionName string, options ...Option) (image.Image, *Options, error)
+
+
 	RegisterConversion(name string, conversion Conversion)
 
 
@@ -23,11 +26,11 @@ type Transformer interface {
 	GetResponsiveImageConversions() map[string]ResponsiveConversion
 
 
-	ResizeImage(img image.Image, width, height int, opts *Options) (image.Image, error)
+	ResizeImage(ctx context.Context, img image.Image, width, height int, opts *Options) (image.Image, error)
 }
 
 
-type Conversion func(img image.Image, opts *Options) (image.Image, error)
+type Conversion func(ctx context.Context, img image.Image, opts *Options) (image.Image, error)
 
 
 type ResponsiveConversion struct {
@@ -45,6 +48,8 @@ type Options struct {
 	Quality     int
 	Format      string
 	Fit         string
+	Gravity     string
+	FocalPoint  *FocalPoint
 	Orientation string
 	Background  string
 	Border      string
@@ -53,6 +58,27 @@ type Options struct {
 	BrightnessQ int
 	ContrastQ   int
 	Watermark   string
+
+	WatermarkDisk     string
+	WatermarkPosition string
+	WatermarkOffsetX  int
+	WatermarkOffsetY  int
+	WatermarkScale    float64
+	WatermarkOpacity  float64
+	WatermarkTile     bool
+
+	SmartCropDebug func(rect image.Rectangle)
+
+	sharedCrop *sharedCrop
+}
+
+
+func WithOptions(options *Options) Option {
+	return func(o *Options) {
+		if options != nil {
+			*o = *options
+		}
+	}
 }
 
 
@@ -91,6 +117,27 @@ func WithFit(fit string) Option {
 }
 
 
+func WithGravity(gravity string) Option {
+	return func(o *Options) {
+		o.Gravity = gravity
+	}
+}
+
+
+func WithFocalPoint(x, y float64) Option {
+	return func(o *Options) {
+		o.FocalPoint = &FocalPoint{X: x, Y: y}
+	}
+}
+
+
+func WithSmartCropDebug(callback func(rect image.Rectangle)) Option {
+	return func(o *Options) {
+		o.SmartCropDebug = callback
+	}
+}
+
+
 func WithOrientation(orientation string) Option {
 	return func(o *Options) {
 		o.Orientation = orientation
@@ -147,11 +194,56 @@ func WithWatermark(watermark string) Option {
 }
 
 
+func WithWatermarkDisk(disk string) Option {
+	return func(o *Options) {
+		o.WatermarkDisk = disk
+	}
+}
+
+
+func WithWatermarkPosition(position string) Option {
+	return func(o *Options) {
+		o.WatermarkPosition = position
+	}
+}
+
+
+func WithWatermarkOffset(x, y int) Option {
+	return func(o *Options) {
+		o.WatermarkOffsetX = x
+		o.WatermarkOffsetY = y
+	}
+}
+
+
+func WithWatermarkScale(scale float64) Option {
+	return func(o *Options) {
+		o.WatermarkScale = scale
+	}
+}
+
+
+func WithWatermarkOpacity(opacity float64) Option {
+	return func(o *Options) {
+		o.WatermarkOpacity = opacity
+	}
+}
+
+
+func WithWatermarkTile(tile bool) Option {
+	return func(o *Options) {
+		o.WatermarkTile = tile
+	}
+}
+
+
 func NewOptions(opts ...Option) *Options {
 	options := &Options{
-		Quality: 90,
-		Format:  "jpg",
-		Fit:     "contain",
+		Quality:           90,
+		Format:            "jpg",
+		Fit:               "contain",
+		WatermarkPosition: GravityBottomRight,
+		WatermarkOpacity:  1,
 	}
 
 	for _, opt := range opts {
@@ -0,0 +1,77 @@
+package conversion
+
+import (
+	"fmt"
+	"strings"
+)
+
+// outputFormat describes a format conversions can be encoded in
+type outputFormat struct {
+	mimeType string
+	alpha    bool
+}
+
+// outputFormats lists the formats there is an encoder for, by their canonical name
+var outputFormats = map[string]outputFormat{
+	"jpg":  {mimeType: "image/jpeg"},
+	"png":  {mimeType: "image/png", alpha: true},
+	"gif":  {mimeType: "image/gif", alpha: true},
+	"bmp":  {mimeType: "image/bmp"},
+	"tiff": {mimeType: "image/tiff", alpha: true},
+}
+
+// formatAliases maps other names of a format to its canonical name
+var formatAliases = map[string]string{
+	"jpeg": "jpg",
+	"tif":  "tiff",
+}
+
+// formatFallbacks maps formats without an encoder to the one they are encoded in instead.
+// WebP has no pure Go encoder, PNG keeps its transparency.
+var formatFallbacks = map[string]string{
+	"webp": "png",
+}
+
+// NormalizeFormat returns the canonical name of a format or file extension, such as "jpg"
+// for ".JPEG". Names that are no alias are returned lower case.
+func NormalizeFormat(format string) string {
+	format = strings.ToLower(strings.TrimPrefix(format, "."))
+	if canonical, ok := formatAliases[format]; ok {
+		return canonical
+	}
+	return format
+}
+
+// OutputFormat returns the format images requested in format are encoded in: its canonical
+// name, or the fallback of a format without an encoder. Unknown formats are an error.
+func OutputFormat(format string) (string, error) {
+	format = NormalizeFormat(format)
+	if fallback, ok := formatFallbacks[format]; ok {
+		format = fallback
+	}
+
+	if _, ok := outputFormats[format]; !ok {
+		return "", fmt.Errorf("unsupported format %q", format)
+	}
+	return format, nil
+}
+
+// FormatMimeType returns the MIME type of the files images requested in format are
+// encoded in, empty for unknown formats.
+func FormatMimeType(format string) string {
+	format, err := OutputFormat(format)
+	if err != nil {
+		return ""
+	}
+	return outputFormats[format].mimeType
+}
+
+// FormatSupportsAlpha reports whether images requested in format keep transparent pixels
+// once encoded.
+func FormatSupportsAlpha(format string) bool {
+	format, err := OutputFormat(format)
+	if err != nil {
+		return false
+	}
+	return outputFormats[format].alpha
+}
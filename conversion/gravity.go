@@ -0,0 +1,110 @@
+package conversion
+
+import (
+	"image"
+	"math"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	GravityCenter      = "center"
+	GravityTop         = "top"
+	GravityBottom      = "bottom"
+	GravityLeft        = "left"
+	GravityRight       = "right"
+	GravityTopLeft     = "top-left"
+	GravityTopRight    = "top-right"
+	GravityBottomLeft  = "bottom-left"
+	GravityBottomRight = "bottom-right"
+)
+
+// FocalPoint is a point of interest in relative coordinates, where 0,0 is the
+// top-left and 1,1 the bottom-right corner of the image.
+type FocalPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+func (fp FocalPoint) Valid() bool {
+	return fp.X >= 0 && fp.X <= 1 && fp.Y >= 0 && fp.Y <= 1
+}
+
+func gravityAnchor(gravity string) imaging.Anchor {
+	switch strings.ToLower(strings.ReplaceAll(gravity, "_", "-")) {
+	case GravityTop, "north":
+		return imaging.Top
+	case GravityBottom, "south":
+		return imaging.Bottom
+	case GravityLeft, "west":
+		return imaging.Left
+	case GravityRight, "east":
+		return imaging.Right
+	case GravityTopLeft, "north-west", "northwest":
+		return imaging.TopLeft
+	case GravityTopRight, "north-east", "northeast":
+		return imaging.TopRight
+	case GravityBottomLeft, "south-west", "southwest":
+		return imaging.BottomLeft
+	case GravityBottomRight, "south-east", "southeast":
+		return imaging.BottomRight
+	default:
+		return imaging.Center
+	}
+}
+
+// anchorPoint returns the top-left position of an item of the given size placed
+// inside the container at the anchor, moved inwards by the offsets.
+func anchorPoint(container image.Rectangle, size image.Point, anchor imaging.Anchor, offsetX, offsetY int) image.Point {
+	minX, minY := container.Min.X, container.Min.Y
+	maxX, maxY := container.Max.X-size.X, container.Max.Y-size.Y
+	midX, midY := minX+(maxX-minX)/2, minY+(maxY-minY)/2
+
+	switch anchor {
+	case imaging.TopLeft:
+		return image.Pt(minX+offsetX, minY+offsetY)
+	case imaging.Top:
+		return image.Pt(midX+offsetX, minY+offsetY)
+	case imaging.TopRight:
+		return image.Pt(maxX-offsetX, minY+offsetY)
+	case imaging.Left:
+		return image.Pt(minX+offsetX, midY+offsetY)
+	case imaging.Right:
+		return image.Pt(maxX-offsetX, midY+offsetY)
+	case imaging.BottomLeft:
+		return image.Pt(minX+offsetX, maxY-offsetY)
+	case imaging.Bottom:
+		return image.Pt(midX+offsetX, maxY-offsetY)
+	case imaging.BottomRight:
+		return image.Pt(maxX-offsetX, maxY-offsetY)
+	default:
+		return image.Pt(midX+offsetX, midY+offsetY)
+	}
+}
+
+// fillRect returns the window of the source that fills the box once resized. The window
+// keeps the focal point as close to its center as the image edges allow, or is placed
+// at the gravity when there is no focal point.
+func fillRect(bounds image.Rectangle, width, height int, opts *Options) image.Rectangle {
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 || srcW == 0 || srcH == 0 {
+		return image.Rectangle{}
+	}
+
+	scale := math.Max(float64(width)/float64(srcW), float64(height)/float64(srcH))
+	cropW := max(min(int(math.Round(float64(width)/scale)), srcW), 1)
+	cropH := max(min(int(math.Round(float64(height)/scale)), srcH), 1)
+
+	if opts.FocalPoint == nil || !opts.FocalPoint.Valid() {
+		pos := anchorPoint(bounds, image.Pt(cropW, cropH), gravityAnchor(opts.Gravity), 0, 0)
+		return image.Rectangle{Min: pos, Max: pos.Add(image.Pt(cropW, cropH))}
+	}
+
+	x := int(math.Round(opts.FocalPoint.X*float64(srcW))) - cropW/2
+	y := int(math.Round(opts.FocalPoint.Y*float64(srcH))) - cropH/2
+	x = max(0, min(x, srcW-cropW))
+	y = max(0, min(y, srcH-cropH))
+
+	return image.Rect(x, y, x+cropW, y+cropH).Add(bounds.Min)
+}
@@ -5,15 +5,23 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"math"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/disintegration/imaging"
+	"github.com/vortechron/go-medialibrary/storage"
 )
 
 
 type ImagingTransformer struct {
 	conversions           map[string]Conversion
 	responsiveConversions map[string]ResponsiveConversion
+	diskManager           *storage.DiskManager
+	watermarks            map[string]image.Image
+	timeouts              map[string]time.Duration
+	defaultTimeout        time.Duration
 	mu                    sync.RWMutex
 }
 
@@ -22,6 +30,8 @@ func NewImagingTransformer() *ImagingTransformer {
 	return &ImagingTransformer{
 		conversions:           make(map[string]Conversion),
 		responsiveConversions: make(map[string]ResponsiveConversion),
+		watermarks:            make(map[string]image.Image),
+		timeouts:              make(map[string]time.Duration),
 	}
 }
 
@@ -74,30 +84,56 @@ func (t *ImagingTransformer) GetResponsiveImageConversions() map[string]Responsi
 
 
 func (t *ImagingTransformer) Transform(ctx context.Context, img image.Image, conversionName string, options ...Option) (image.Image, error) {
+	result, _, err := t.TransformWithOptions(ctx, img, conversionName, options...)
+	return result, err
+}
+
+
+func (t *ImagingTransformer) TransformWithOptions(ctx context.Context, img image.Image, conversionName string, options ...Option) (image.Image, *Options, error) {
 	t.mu.RLock()
 	conversion, exists := t.conversions[conversionName]
+	timeout := t.conversionTimeout(conversionName)
 	t.mu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("conversion not found: %s", conversionName)
+		return nil, nil, fmt.Errorf("conversion not found: %s", conversionName)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
 	}
 
 	opts := NewOptions(options...)
+	if opts.Format != "" {
+		if _, err := OutputFormat(opts.Format); err != nil {
+			return nil, nil, err
+		}
+	}
 
+	runCtx, cancel, timeout := withConversionDeadline(ctx, timeout)
+	defer cancel()
 
-	return conversion(img, opts)
+	result, err := conversion(runCtx, img, opts)
+	if err == nil {
+		err = runCtx.Err()
+	}
+	if err != nil {
+		return nil, nil, timeoutError(ctx, runCtx, conversionName, timeout, err)
+	}
+
+	return result, opts, nil
 }
 
 
 func (t *ImagingTransformer) DefaultConversions() {
 
-	t.RegisterConversion("thumbnail", func(img image.Image, opts *Options) (image.Image, error) {
-		return t.ResizeImage(img, 150, 150, opts)
+	t.RegisterConversion("thumbnail", func(ctx context.Context, img image.Image, opts *Options) (image.Image, error) {
+		return t.ResizeImage(ctx, img, 150, 150, opts)
 	})
 
 
-	t.RegisterConversion("preview", func(img image.Image, opts *Options) (image.Image, error) {
-		return t.ResizeImage(img, 600, 400, opts)
+	t.RegisterConversion("preview", func(ctx context.Context, img image.Image, opts *Options) (image.Image, error) {
+		return t.ResizeImage(ctx, img, 600, 400, opts)
 	})
 }
 
@@ -111,9 +147,17 @@ func (t *ImagingTransformer) DefaultResponsiveConversions() {
 }
 
 
-func (t *ImagingTransformer) ResizeImage(img image.Image, width, height int, opts *Options) (image.Image, error) {
+func (t *ImagingTransformer) ResizeImage(ctx context.Context, img image.Image, width, height int, opts *Options) (image.Image, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
+	// An overridden width keeps the aspect ratio of the requested box
 	if opts.Width > 0 {
+		if opts.Height <= 0 && width > 0 && height > 0 {
+			height = max(height*opts.Width/width, 1)
+		}
 		width = opts.Width
 	}
 
@@ -121,6 +165,15 @@ func (t *ImagingTransformer) ResizeImage(img image.Image, width, height int, opt
 		height = opts.Height
 	}
 
+
+	bounds := img.Bounds()
+	if height <= 0 && width > 0 && bounds.Dx() > 0 {
+		height = max(int(math.Round(float64(bounds.Dy())*float64(width)/float64(bounds.Dx()))), 1)
+	}
+	if width <= 0 && height > 0 && bounds.Dy() > 0 {
+		width = max(int(math.Round(float64(bounds.Dx())*float64(height)/float64(bounds.Dy()))), 1)
+	}
+
 	var result image.Image
 
 
@@ -130,7 +183,27 @@ func (t *ImagingTransformer) ResizeImage(img image.Image, width, height int, opt
 	case "max":
 		result = imaging.Resize(img, width, 0, imaging.Lanczos)
 	case "fill":
-		result = imaging.Fill(img, width, height, imaging.Center, imaging.Lanczos)
+		rect, _, _ := opts.cropWindow(func() (image.Rectangle, error) {
+			return fillRect(img.Bounds(), width, height, opts), nil
+		})
+		if rect.Empty() {
+			result = &image.NRGBA{}
+		} else {
+			result = imaging.Resize(imaging.Crop(img, rect), width, height, imaging.Lanczos)
+		}
+	case "smart":
+		rect, chosen, err := opts.cropWindow(func() (image.Rectangle, error) {
+			return SmartCrop(img, width, height)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find smart crop: %w", err)
+		}
+		if chosen && opts.SmartCropDebug != nil {
+			opts.SmartCropDebug(rect)
+		}
+		result = imaging.Resize(imaging.Crop(img, rect), width, height, imaging.Lanczos)
+	case "pad":
+		result = pad(img, width, height, opts)
 	case "stretch":
 		result = imaging.Resize(img, width, height, imaging.Lanczos)
 	default:
@@ -138,6 +211,10 @@ func (t *ImagingTransformer) ResizeImage(img image.Image, width, height int, opt
 		result = imaging.Fit(img, width, height, imaging.Lanczos)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 
 	if opts.Blur > 0 {
 		result = imaging.Blur(result, float64(opts.Blur))
@@ -147,6 +224,10 @@ func (t *ImagingTransformer) ResizeImage(img image.Image, width, height int, opt
 		result = imaging.Sharpen(result, float64(opts.Sharpen))
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	if opts.BrightnessQ != 0 {
 		result = imaging.AdjustBrightness(result, float64(opts.BrightnessQ))
 	}
@@ -155,6 +236,14 @@ func (t *ImagingTransformer) ResizeImage(img image.Image, width, height int, opt
 		result = imaging.AdjustContrast(result, float64(opts.ContrastQ))
 	}
 
+	if opts.Watermark != "" {
+		watermarked, err := t.applyWatermark(ctx, result, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply watermark: %w", err)
+		}
+		result = watermarked
+	}
+
 
 	if opts.Border != "" {
 		borderColor, err := parseHexColor(opts.Border)
@@ -164,6 +253,14 @@ func (t *ImagingTransformer) ResizeImage(img image.Image, width, height int, opt
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if !FormatSupportsAlpha(opts.Format) {
+		result = flatten(result, opts)
+	}
+
 	return result, nil
 }
 
@@ -171,7 +268,11 @@ func (t *ImagingTransformer) ResizeImage(img image.Image, width, height int, opt
 func parseHexColor(s string) (c color.NRGBA, err error) {
 	c.A = 0xff
 
-	if s[0] != '#' {
+	if strings.EqualFold(s, "transparent") {
+		return color.NRGBA{}, nil
+	}
+
+	if len(s) == 0 || s[0] != '#' {
 		return c, fmt.Errorf("invalid hex color format")
 	}
 
@@ -189,10 +290,16 @@ func parseHexColor(s string) (c color.NRGBA, err error) {
 	}
 
 	switch len(s) {
+	case 9:
+		c.A = hexToByte(s[7])<<4 + hexToByte(s[8])
+		fallthrough
 	case 7:
 		c.R = hexToByte(s[1])<<4 + hexToByte(s[2])
 		c.G = hexToByte(s[3])<<4 + hexToByte(s[4])
 		c.B = hexToByte(s[5])<<4 + hexToByte(s[6])
+	case 5:
+		c.A = hexToByte(s[4]) * 17
+		fallthrough
 	case 4:
 		c.R = hexToByte(s[1]) * 17
 		c.G = hexToByte(s[2]) * 17
@@ -0,0 +1,411 @@
+package conversion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	StepResize     = "resize"
+	StepFit        = "fit"
+	StepCrop       = "crop"
+	StepRotate     = "rotate"
+	StepFlip       = "flip"
+	StepBlur       = "blur"
+	StepSharpen    = "sharpen"
+	StepBrightness = "brightness"
+	StepContrast   = "contrast"
+	StepGrayscale  = "grayscale"
+	StepBorder     = "border"
+	StepWatermark  = "watermark"
+	StepFormat     = "format"
+	StepQuality    = "quality"
+)
+
+// Step is a single operation of a declarative pipeline. Only the fields used by
+// the operation need to be set.
+type Step struct {
+	Op string `json:"op" yaml:"op"`
+
+	Width      int     `json:"width,omitempty" yaml:"width,omitempty"`
+	Height     int     `json:"height,omitempty" yaml:"height,omitempty"`
+	X          int     `json:"x,omitempty" yaml:"x,omitempty"`
+	Y          int     `json:"y,omitempty" yaml:"y,omitempty"`
+	Mode       string  `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Gravity    string  `json:"gravity,omitempty" yaml:"gravity,omitempty"`
+	Background string  `json:"background,omitempty" yaml:"background,omitempty"`
+	Angle      float64 `json:"angle,omitempty" yaml:"angle,omitempty"`
+	Direction  string  `json:"direction,omitempty" yaml:"direction,omitempty"`
+	Amount     float64 `json:"amount,omitempty" yaml:"amount,omitempty"`
+	Color      string  `json:"color,omitempty" yaml:"color,omitempty"`
+
+	Path     string   `json:"path,omitempty" yaml:"path,omitempty"`
+	Disk     string   `json:"disk,omitempty" yaml:"disk,omitempty"`
+	Position string   `json:"position,omitempty" yaml:"position,omitempty"`
+	OffsetX  int      `json:"offset_x,omitempty" yaml:"offset_x,omitempty"`
+	OffsetY  int      `json:"offset_y,omitempty" yaml:"offset_y,omitempty"`
+	Scale    float64  `json:"scale,omitempty" yaml:"scale,omitempty"`
+	Opacity  *float64 `json:"opacity,omitempty" yaml:"opacity,omitempty"`
+	Tile     bool     `json:"tile,omitempty" yaml:"tile,omitempty"`
+
+	Format  string `json:"format,omitempty" yaml:"format,omitempty"`
+	Quality int    `json:"quality,omitempty" yaml:"quality,omitempty"`
+}
+
+// Pipeline is a named list of steps. When widths are set the pipeline is also
+// registered as a responsive image conversion. Timeout is a duration such as
+// "5s" that limits how long the conversion may run.
+type Pipeline struct {
+	Steps   []Step `json:"steps" yaml:"steps"`
+	Widths  []int  `json:"widths,omitempty" yaml:"widths,omitempty"`
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// PipelineFile is the document format of pipeline files.
+type PipelineFile struct {
+	Conversions map[string]Pipeline `json:"conversions" yaml:"conversions"`
+}
+
+// ParsePipelines decodes a pipeline document. The format is "json" or "yaml".
+func ParsePipelines(r io.Reader, format string) (map[string]Pipeline, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipelines: %w", err)
+	}
+
+	var file PipelineFile
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	case "yaml", "yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&file)
+	default:
+		return nil, fmt.Errorf("unsupported pipeline format: %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode pipelines: %w", err)
+	}
+
+	for name, pipeline := range file.Conversions {
+		if err := pipeline.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid pipeline %s: %w", name, err)
+		}
+	}
+
+	return file.Conversions, nil
+}
+
+// LoadPipelinesFromFile reads a pipeline document, detecting the format from
+// the file extension.
+func LoadPipelinesFromFile(path string) (map[string]Pipeline, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pipeline file: %w", err)
+	}
+	defer file.Close()
+
+	return ParsePipelines(file, filepath.Ext(path))
+}
+
+// Validate checks that every step is a known operation with usable arguments.
+func (p Pipeline) Validate() error {
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("pipeline has no steps")
+	}
+
+	for _, width := range p.Widths {
+		if width <= 0 {
+			return fmt.Errorf("invalid responsive width %d", width)
+		}
+	}
+
+	if _, err := p.timeout(); err != nil {
+		return err
+	}
+
+	for i, step := range p.Steps {
+		if err := step.validate(); err != nil {
+			return fmt.Errorf("step %d (%s): %w", i+1, step.Op, err)
+		}
+	}
+
+	return nil
+}
+
+func (p Pipeline) timeout() (time.Duration, error) {
+	if p.Timeout == "" {
+		return 0, nil
+	}
+
+	timeout, err := time.ParseDuration(p.Timeout)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid timeout %q", p.Timeout)
+	}
+	return timeout, nil
+}
+
+func (s Step) validate() error {
+	switch s.Op {
+	case StepResize:
+		if s.Width <= 0 && s.Height <= 0 {
+			return fmt.Errorf("width or height is required")
+		}
+	case StepFit:
+		if s.Width <= 0 || s.Height <= 0 {
+			return fmt.Errorf("width and height are required")
+		}
+		switch s.Mode {
+		case "", "contain", "max", "fill", "smart", "pad", "stretch":
+		default:
+			return fmt.Errorf("unknown fit mode %q", s.Mode)
+		}
+	case StepCrop:
+		if s.Width <= 0 || s.Height <= 0 {
+			return fmt.Errorf("width and height are required")
+		}
+	case StepFlip:
+		if s.Direction != "horizontal" && s.Direction != "vertical" {
+			return fmt.Errorf("direction must be horizontal or vertical")
+		}
+	case StepBlur, StepSharpen:
+		if s.Amount <= 0 {
+			return fmt.Errorf("amount must be positive")
+		}
+	case StepBrightness, StepContrast:
+		if s.Amount < -100 || s.Amount > 100 {
+			return fmt.Errorf("amount must be between -100 and 100")
+		}
+	case StepBorder:
+		if s.Width <= 0 {
+			return fmt.Errorf("width must be positive")
+		}
+		if _, err := parseHexColor(s.Color); err != nil {
+			return fmt.Errorf("invalid color %q", s.Color)
+		}
+	case StepWatermark:
+		if s.Path == "" {
+			return fmt.Errorf("path is required")
+		}
+		if s.Opacity != nil && (*s.Opacity < 0 || *s.Opacity > 1) {
+			return fmt.Errorf("opacity must be between 0 and 1")
+		}
+	case StepFormat:
+		if _, err := OutputFormat(s.Format); err != nil {
+			return err
+		}
+	case StepQuality:
+		if s.Quality < 1 || s.Quality > 100 {
+			return fmt.Errorf("quality must be between 1 and 100")
+		}
+	case StepRotate, StepGrayscale:
+	default:
+		return fmt.Errorf("unknown operation")
+	}
+
+	return nil
+}
+
+// CompilePipeline turns a pipeline into a conversion. Format and quality steps
+// apply to the whole conversion regardless of their position.
+func (t *ImagingTransformer) CompilePipeline(p Pipeline) (Conversion, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	steps := make([]Step, len(p.Steps))
+	copy(steps, p.Steps)
+
+	return func(ctx context.Context, img image.Image, opts *Options) (image.Image, error) {
+		for _, step := range steps {
+			switch step.Op {
+			case StepFormat:
+				opts.Format = strings.ToLower(step.Format)
+			case StepQuality:
+				opts.Quality = step.Quality
+			}
+		}
+
+		result := img
+		for _, step := range steps {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			var err error
+			result, err = t.applyStep(ctx, result, step, opts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to apply %s: %w", step.Op, err)
+			}
+		}
+
+		if !FormatSupportsAlpha(opts.Format) {
+			result = flatten(result, opts)
+		}
+
+		return result, nil
+	}, nil
+}
+
+// RegisterPipelines compiles and registers the pipelines. Nothing is registered
+// if any of them fails to compile, so it is safe to call again to reload.
+func (t *ImagingTransformer) RegisterPipelines(pipelines map[string]Pipeline) error {
+	compiled := make(map[string]Conversion, len(pipelines))
+	for name, pipeline := range pipelines {
+		conversion, err := t.CompilePipeline(pipeline)
+		if err != nil {
+			return fmt.Errorf("invalid pipeline %s: %w", name, err)
+		}
+		compiled[name] = conversion
+	}
+
+	for name, conversion := range compiled {
+		timeout, _ := pipelines[name].timeout()
+		t.RegisterConversion(name, conversion)
+		t.SetConversionTimeout(name, timeout)
+		if widths := pipelines[name].Widths; len(widths) > 0 {
+			t.RegisterResponsiveImageConversion(name, widths)
+		}
+	}
+
+	return nil
+}
+
+// RegisterPipelinesFromFile loads and registers the pipelines of a JSON or YAML file.
+func (t *ImagingTransformer) RegisterPipelinesFromFile(path string) error {
+	pipelines, err := LoadPipelinesFromFile(path)
+	if err != nil {
+		return err
+	}
+
+	return t.RegisterPipelines(pipelines)
+}
+
+func (t *ImagingTransformer) applyStep(ctx context.Context, img image.Image, step Step, opts *Options) (image.Image, error) {
+	switch step.Op {
+	case StepResize:
+		width, height := targetSize(step.Width, step.Height, opts)
+		return imaging.Resize(img, width, height, imaging.Lanczos), nil
+
+	case StepFit:
+		width, height := targetSize(step.Width, step.Height, opts)
+		fitOpts := &Options{
+			Width:      width,
+			Height:     height,
+			Format:     opts.Format,
+			Fit:        step.Mode,
+			Gravity:    step.Gravity,
+			FocalPoint: opts.FocalPoint,
+			Background: step.Background,
+		}
+		if fitOpts.Fit == "" {
+			fitOpts.Fit = "contain"
+		}
+		if fitOpts.Background == "" {
+			fitOpts.Background = opts.Background
+		}
+		fitOpts.SmartCropDebug = opts.SmartCropDebug
+		return t.ResizeImage(ctx, img, width, height, fitOpts)
+
+	case StepCrop:
+		if step.Gravity != "" {
+			return imaging.CropAnchor(img, step.Width, step.Height, gravityAnchor(step.Gravity)), nil
+		}
+		origin := img.Bounds().Min.Add(image.Pt(step.X, step.Y))
+		return imaging.Crop(img, image.Rectangle{Min: origin, Max: origin.Add(image.Pt(step.Width, step.Height))}), nil
+
+	case StepRotate:
+		// Angles are clockwise, imaging rotates counter-clockwise
+		switch step.Angle {
+		case 0, 360, -360:
+			return img, nil
+		case 90, -270:
+			return imaging.Rotate270(img), nil
+		case 180, -180:
+			return imaging.Rotate180(img), nil
+		case 270, -90:
+			return imaging.Rotate90(img), nil
+		}
+		bgOpts := &Options{Format: opts.Format, Background: step.Background}
+		return imaging.Rotate(img, -step.Angle, backgroundColor(bgOpts)), nil
+
+	case StepFlip:
+		if step.Direction == "vertical" {
+			return imaging.FlipV(img), nil
+		}
+		return imaging.FlipH(img), nil
+
+	case StepBlur:
+		return imaging.Blur(img, step.Amount), nil
+
+	case StepSharpen:
+		return imaging.Sharpen(img, step.Amount), nil
+
+	case StepBrightness:
+		return imaging.AdjustBrightness(img, step.Amount), nil
+
+	case StepContrast:
+		return imaging.AdjustContrast(img, step.Amount), nil
+
+	case StepGrayscale:
+		return imaging.Grayscale(img), nil
+
+	case StepBorder:
+		borderColor, err := parseHexColor(step.Color)
+		if err != nil {
+			return nil, err
+		}
+		bounds := img.Bounds()
+		canvas := imaging.New(bounds.Dx()+2*step.Width, bounds.Dy()+2*step.Width, borderColor)
+		return imaging.Overlay(canvas, img, image.Pt(step.Width, step.Width), 1), nil
+
+	case StepWatermark:
+		disk := step.Disk
+		if disk == "" {
+			disk = opts.WatermarkDisk
+		}
+		watermarkOpts := NewOptions(
+			WithWatermark(step.Path),
+			WithWatermarkDisk(disk),
+			WithWatermarkOffset(step.OffsetX, step.OffsetY),
+			WithWatermarkScale(step.Scale),
+			WithWatermarkTile(step.Tile),
+		)
+		if step.Position != "" {
+			watermarkOpts.WatermarkPosition = step.Position
+		}
+		if step.Opacity != nil {
+			watermarkOpts.WatermarkOpacity = *step.Opacity
+		}
+		return t.applyWatermark(ctx, img, watermarkOpts)
+	}
+
+	return img, nil
+}
+
+// targetSize returns the size of a sizing step. A width requested by the caller,
+// as for responsive images, replaces the step width and scales the height.
+func targetSize(width, height int, opts *Options) (int, int) {
+	if opts.Width <= 0 {
+		return width, height
+	}
+
+	if width > 0 && height > 0 {
+		height = max(height*opts.Width/width, 1)
+	}
+	return opts.Width, height
+}
@@ -0,0 +1,191 @@
+package conversion
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	smartCropAnalysisSize = 256
+	smartCropSteps        = 32
+	smartCropBins         = 16
+
+	smartCropEdgeWeight       = 1.0
+	smartCropSaturationWeight = 0.6
+	smartCropEntropyWeight    = 0.15
+)
+
+var smartCropScales = []float64{1, 0.9, 0.8}
+
+// SmartCrop finds the crop window with the aspect ratio of width x height that
+// maximizes the edge, entropy and saturation scores of the image. The returned
+// rectangle is in the coordinates of img.
+func SmartCrop(img image.Image, width, height int) (image.Rectangle, error) {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 {
+		return image.Rectangle{}, fmt.Errorf("invalid crop size %dx%d", width, height)
+	}
+	if srcW == 0 || srcH == 0 {
+		return image.Rectangle{}, fmt.Errorf("empty image")
+	}
+
+	// Analyse a downscaled copy, scores only need to be roughly right
+	var analysis *image.NRGBA
+	factor := 1.0
+	if srcW > smartCropAnalysisSize || srcH > smartCropAnalysisSize {
+		analysis = imaging.Fit(img, smartCropAnalysisSize, smartCropAnalysisSize, imaging.Box)
+		factor = float64(srcW) / float64(analysis.Bounds().Dx())
+	} else {
+		analysis = imaging.Clone(img)
+	}
+
+	scores := newScoreMap(analysis)
+	w, h := scores.width, scores.height
+
+	aspect := float64(width) / float64(height)
+	baseW, baseH := float64(w), float64(w)/aspect
+	if baseH > float64(h) {
+		baseW, baseH = float64(h)*aspect, float64(h)
+	}
+
+	best := image.Rectangle{}
+	bestScore := math.Inf(-1)
+
+	for _, scale := range smartCropScales {
+		cropW := max(int(baseW*scale), 1)
+		cropH := max(int(baseH*scale), 1)
+		stepX := max((w-cropW)/smartCropSteps, 1)
+		stepY := max((h-cropH)/smartCropSteps, 1)
+
+		for y := 0; y+cropH <= h; y += stepY {
+			for x := 0; x+cropW <= w; x += stepX {
+				rect := image.Rect(x, y, x+cropW, y+cropH)
+				score := scores.score(rect) * scale
+				if score > bestScore {
+					bestScore = score
+					best = rect
+				}
+			}
+		}
+	}
+
+	// Map the window back to the original image
+	result := image.Rect(
+		int(math.Round(float64(best.Min.X)*factor)),
+		int(math.Round(float64(best.Min.Y)*factor)),
+		int(math.Round(float64(best.Max.X)*factor)),
+		int(math.Round(float64(best.Max.Y)*factor)),
+	).Add(bounds.Min)
+
+	return result.Intersect(bounds), nil
+}
+
+// FocalPointFromRect returns the center of rect relative to bounds.
+func FocalPointFromRect(bounds, rect image.Rectangle) FocalPoint {
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return FocalPoint{X: 0.5, Y: 0.5}
+	}
+
+	center := rect.Min.Add(rect.Max).Div(2).Sub(bounds.Min)
+	return FocalPoint{
+		X: float64(center.X) / float64(bounds.Dx()),
+		Y: float64(center.Y) / float64(bounds.Dy()),
+	}
+}
+
+// scoreMap holds summed-area tables of the per-pixel detail score and of the
+// luminance histogram, so any window can be scored in constant time.
+type scoreMap struct {
+	width, height int
+	detail        []float64
+	histogram     [smartCropBins][]int32
+}
+
+func newScoreMap(img *image.NRGBA) *scoreMap {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	stride := w + 1
+
+	sm := &scoreMap{
+		width:  w,
+		height: h,
+		detail: make([]float64, stride*(h+1)),
+	}
+	for i := range sm.histogram {
+		sm.histogram[i] = make([]int32, stride*(h+1))
+	}
+
+	luma := make([]float64, w*h)
+	saturation := make([]float64, w*h)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := img.PixOffset(x, y)
+			r := float64(img.Pix[i]) / 255
+			g := float64(img.Pix[i+1]) / 255
+			b := float64(img.Pix[i+2]) / 255
+			a := float64(img.Pix[i+3]) / 255
+
+			luma[y*w+x] = (0.2126*r + 0.7152*g + 0.0722*b) * a
+
+			maxC := math.Max(r, math.Max(g, b))
+			minC := math.Min(r, math.Min(g, b))
+			if maxC > 0 {
+				saturation[y*w+x] = (maxC - minC) / maxC * a
+			}
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		var rowDetail float64
+		var rowBins [smartCropBins]int32
+
+		for x := 0; x < w; x++ {
+			l := luma[y*w+x]
+
+			// Laplacian of the luminance as edge detector
+			edge := 4 * l
+			edge -= luma[y*w+max(x-1, 0)]
+			edge -= luma[y*w+min(x+1, w-1)]
+			edge -= luma[max(y-1, 0)*w+x]
+			edge -= luma[min(y+1, h-1)*w+x]
+
+			rowDetail += smartCropEdgeWeight*math.Abs(edge) + smartCropSaturationWeight*saturation[y*w+x]
+			rowBins[min(int(l*smartCropBins), smartCropBins-1)]++
+
+			i := (y+1)*stride + x + 1
+			sm.detail[i] = sm.detail[i-stride] + rowDetail
+			for bin := range sm.histogram {
+				sm.histogram[bin][i] = sm.histogram[bin][i-stride] + rowBins[bin]
+			}
+		}
+	}
+
+	return sm
+}
+
+func (sm *scoreMap) score(rect image.Rectangle) float64 {
+	stride := sm.width + 1
+	a := rect.Min.Y*stride + rect.Min.X
+	b := rect.Min.Y*stride + rect.Max.X
+	c := rect.Max.Y*stride + rect.Min.X
+	d := rect.Max.Y*stride + rect.Max.X
+
+	area := float64(rect.Dx() * rect.Dy())
+	detail := (sm.detail[d] - sm.detail[b] - sm.detail[c] + sm.detail[a]) / area
+
+	var entropy float64
+	for bin := range sm.histogram {
+		h := sm.histogram[bin]
+		count := float64(h[d] - h[b] - h[c] + h[a])
+		if count > 0 {
+			p := count / area
+			entropy -= p * math.Log2(p)
+		}
+	}
+
+	return detail + smartCropEntropyWeight*entropy
+}
@@ -0,0 +1,73 @@
+package conversion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrConversionTimeout is returned when a conversion runs longer than its
+// configured timeout.
+var ErrConversionTimeout = errors.New("conversion timed out")
+
+// SetConversionTimeout limits how long the named conversion may run. A zero
+// timeout removes the limit.
+func (t *ImagingTransformer) SetConversionTimeout(name string, timeout time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if timeout <= 0 {
+		delete(t.timeouts, name)
+		return
+	}
+	t.timeouts[name] = timeout
+}
+
+// SetDefaultConversionTimeout limits how long conversions without their own
+// timeout may run. A zero timeout removes the limit.
+func (t *ImagingTransformer) SetDefaultConversionTimeout(timeout time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.defaultTimeout = timeout
+}
+
+// conversionTimeout must be called with the lock held.
+func (t *ImagingTransformer) conversionTimeout(name string) time.Duration {
+	if timeout, ok := t.timeouts[name]; ok {
+		return timeout
+	}
+	return t.defaultTimeout
+}
+
+// ConversionTimeout returns the timeout of the named conversion, zero when it is unlimited.
+func (t *ImagingTransformer) ConversionTimeout(name string) time.Duration {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.conversionTimeout(name)
+}
+
+// conversionDeadlineKey marks contexts that already carry the deadline of a conversion.
+type conversionDeadlineKey struct{}
+
+// withConversionDeadline starts the timeout of a conversion. Contexts that already carry
+// a conversion deadline, such as those of the frames of an animated GIF, are returned
+// unchanged so the timeout bounds the whole conversion and not every frame.
+func withConversionDeadline(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc, time.Duration) {
+	if timeout <= 0 || ctx.Value(conversionDeadlineKey{}) != nil {
+		return ctx, func() {}, 0
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, conversionDeadlineKey{}, true), timeout)
+	return ctx, cancel, timeout
+}
+
+// timeoutError reports an expired conversion deadline as ErrConversionTimeout.
+func timeoutError(ctx, runCtx context.Context, name string, timeout time.Duration, err error) error {
+	if timeout > 0 && ctx.Err() == nil && runCtx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%w: %s exceeded %s", ErrConversionTimeout, name, timeout)
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package conversion
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/disintegration/imaging"
+	"github.com/vortechron/go-medialibrary/storage"
+)
+
+func (t *ImagingTransformer) SetDiskManager(diskManager *storage.DiskManager) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.diskManager = diskManager
+}
+
+func (t *ImagingTransformer) ClearWatermarkCache() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.watermarks = make(map[string]image.Image)
+}
+
+// loadWatermark returns the decoded watermark image, downloading it from the
+// disk on first use and serving it from the cache afterwards.
+func (t *ImagingTransformer) loadWatermark(ctx context.Context, diskName, path string) (image.Image, error) {
+	key := diskName + ":" + path
+
+	t.mu.RLock()
+	watermark, cached := t.watermarks[key]
+	diskManager := t.diskManager
+	t.mu.RUnlock()
+
+	if cached {
+		return watermark, nil
+	}
+
+	if diskManager == nil {
+		return nil, fmt.Errorf("no disk manager configured for loading watermarks")
+	}
+
+	if diskName == "" {
+		return nil, fmt.Errorf("watermark disk not specified")
+	}
+
+	disk, err := diskManager.GetDisk(diskName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watermark disk %s: %w", diskName, err)
+	}
+
+	reader, err := disk.Get(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watermark %s: %w", path, err)
+	}
+	defer reader.Close()
+
+	watermark, _, err = image.Decode(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode watermark %s: %w", path, err)
+	}
+
+	t.mu.Lock()
+	t.watermarks[key] = watermark
+	t.mu.Unlock()
+
+	return watermark, nil
+}
+
+func (t *ImagingTransformer) applyWatermark(ctx context.Context, img image.Image, opts *Options) (image.Image, error) {
+	watermark, err := t.loadWatermark(ctx, opts.WatermarkDisk, opts.Watermark)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+
+	if opts.WatermarkScale > 0 {
+		width := int(float64(bounds.Dx()) * opts.WatermarkScale)
+		if width < 1 {
+			width = 1
+		}
+		watermark = imaging.Resize(watermark, width, 0, imaging.Lanczos)
+	}
+
+	// NewOptions defaults the opacity to 1, an explicit 0 leaves the image unchanged
+	opacity := opts.WatermarkOpacity
+	if opacity < 0 || opacity > 1 {
+		return nil, fmt.Errorf("watermark opacity must be between 0 and 1, got %g", opacity)
+	}
+
+	size := watermark.Bounds().Size()
+	if size.X == 0 || size.Y == 0 || opacity == 0 {
+		return img, nil
+	}
+
+	if !opts.WatermarkTile {
+		pos := anchorPoint(bounds, size, gravityAnchor(opts.WatermarkPosition), opts.WatermarkOffsetX, opts.WatermarkOffsetY)
+		return imaging.Overlay(img, watermark, pos, opacity), nil
+	}
+
+	// Offsets are used as the gap between tiles
+	stepX := max(size.X+opts.WatermarkOffsetX, 1)
+	stepY := max(size.Y+opts.WatermarkOffsetY, 1)
+
+	// Draw every tile onto one copy instead of copying the image per tile
+	result := imaging.Clone(img)
+	mask := image.NewUniform(color.Alpha{A: uint8(opacity*255 + 0.5)})
+	origin := watermark.Bounds().Min
+	for y := 0; y < bounds.Dy(); y += stepY {
+		for x := 0; x < bounds.Dx(); x += stepX {
+			tile := image.Rectangle{Min: image.Pt(x, y), Max: image.Pt(x+size.X, y+size.Y)}
+			draw.DrawMask(result, tile, watermark, origin, mask, image.Point{}, draw.Over)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,72 @@
+package generator
+
+import (
+	"context"
+	"image"
+	"io"
+	"sort"
+	"sync"
+)
+
+// ImageGenerator turns a source file that cannot be decoded as an image, such
+// as an SVG, PDF or video, into an image that conversions can run on.
+type ImageGenerator interface {
+	Generate(ctx context.Context, source io.Reader) (image.Image, error)
+}
+
+// Func adapts a function to the ImageGenerator interface.
+type Func func(ctx context.Context, source io.Reader) (image.Image, error)
+
+func (f Func) Generate(ctx context.Context, source io.Reader) (image.Image, error) {
+	return f(ctx, source)
+}
+
+// Registry holds the image generators by MIME type.
+type Registry struct {
+	generators map[string]ImageGenerator
+	mu         sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		generators: make(map[string]ImageGenerator),
+	}
+}
+
+// NewDefaultRegistry returns a registry with the generators shipped with the
+// library registered.
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register("image/svg+xml", NewSVGGenerator(DefaultSVGSize))
+	return r
+}
+
+// Register sets the generator for a MIME type, replacing any previous one.
+func (r *Registry) Register(mimeType string, generator ImageGenerator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.generators[mimeType] = generator
+}
+
+func (r *Registry) Get(mimeType string) (ImageGenerator, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	generator, ok := r.generators[mimeType]
+	return generator, ok
+}
+
+// MimeTypes returns the registered MIME types in sorted order.
+func (r *Registry) MimeTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	mimeTypes := make([]string, 0, len(r.generators))
+	for mimeType := range r.generators {
+		mimeTypes = append(mimeTypes, mimeType)
+	}
+	sort.Strings(mimeTypes)
+
+	return mimeTypes
+}
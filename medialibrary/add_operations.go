@@ -1,12 +1,14 @@
 package medialibrary
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"net/http"
 	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -16,6 +18,19 @@ import (
 	"github.com/vortechron/go-medialibrary/storage"
 )
 
+// ErrDownloadTooLarge is returned by AddMediaFromURL for files above the maximum download size
+var ErrDownloadTooLarge = errors.New("download exceeds the maximum size")
+
+const (
+	// defaultDownloadTimeout bounds downloads made with the default HTTP client
+	defaultDownloadTimeout = time.Minute
+
+	// defaultMaxDownloadSize is the largest file downloaded without WithMaxDownloadSize
+	defaultMaxDownloadSize = 100 << 20
+)
+
+var defaultHTTPClient = &http.Client{Timeout: defaultDownloadTimeout}
+
 // AddMediaFromURL adds a media item from a URL
 func (m *DefaultMediaLibrary) AddMediaFromURL(
 	ctx context.Context,
@@ -69,6 +84,12 @@ func (m *DefaultMediaLibrary) AddMediaFromURL(
 		return nil, fmt.Errorf("failed to get disk %s: %w", diskName, err)
 	}
 
+	visibility, err := m.visibilityFor(collection, opts)
+	if err != nil {
+		m.logger.Error("Failed to resolve visibility: %v", err)
+		return nil, err
+	}
+
 	media := &models.Media{
 		ModelType:            opts.ModelType,
 		ModelID:              opts.ModelID,
@@ -78,10 +99,12 @@ func (m *DefaultMediaLibrary) AddMediaFromURL(
 		FileName:             baseName,
 		Disk:                 diskName,
 		ConversionsDisk:      opts.ConversionsDisk,
+		Visibility:           visibility,
 		Manipulations:        json.RawMessage("{}"),
 		CustomProperties:     json.RawMessage("{}"),
 		GeneratedConversions: json.RawMessage("{}"),
 		ResponsiveImages:     json.RawMessage("{}"),
+		Metadata:             json.RawMessage("{}"),
 		CreatedAt:            time.Now(),
 		UpdatedAt:            time.Now(),
 	}
@@ -95,87 +118,70 @@ func (m *DefaultMediaLibrary) AddMediaFromURL(
 		media.CustomProperties = customPropsBytes
 	}
 
-	// Set a dummy size and mime type initially
-	media.Size = 0
-	media.MimeType = getMimeTypeFromExtension(filepath.Ext(media.FileName))
-	m.logger.Debug("Detected mime type: %s", media.MimeType)
-
-	// Save to DB first to get the ID
-	err = m.repository.Save(ctx, media)
-	if err != nil {
-		m.logger.Error("Failed to save media: %v", err)
-		return nil, fmt.Errorf("failed to save media: %w", err)
+	// Download to a temporary file so the metadata policy is applied before anything is stored
+	client := m.defaultOptions.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
 	}
-	m.logger.Info("Initially saved media with ID %d", media.ID)
 
-	// Now we have the ID, we can generate the proper path
-	path := m.pathGenerator.GetPath(media)
-	m.logger.Info("Saving media from URL %s to path %s", urlStr, path)
-
-	err = disk.SaveFromURL(ctx, path, urlStr,
-		storage.WithVisibility("public"))
-	if err != nil {
-		m.logger.Error("Failed to download and store file: %v", err)
-		return nil, fmt.Errorf("failed to download and store file: %w", err)
+	maxSize := m.defaultOptions.MaxDownloadSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxDownloadSize
 	}
 
-	exists, err := disk.Exists(ctx, path)
-	if err != nil || !exists {
-		m.logger.Error("Failed to verify file existence: %v", err)
-		return nil, fmt.Errorf("failed to verify file existence: %w", err)
+	file, err := downloadToTempFile(ctx, client, urlStr, maxSize)
+	if err != nil {
+		m.logger.Error("Failed to download file: %v", err)
+		return nil, fmt.Errorf("failed to download file: %w", err)
 	}
+	defer os.Remove(file.Name())
+	defer file.Close()
 
-	fileReader, err := disk.Get(ctx, path)
+	info, err := file.Stat()
 	if err != nil {
-		m.logger.Error("Failed to get file: %v", err)
-		return nil, fmt.Errorf("failed to get file: %w", err)
+		m.logger.Error("Failed to stat downloaded file: %v", err)
+		return nil, fmt.Errorf("failed to stat downloaded file: %w", err)
 	}
-	defer fileReader.Close()
+	media.Size = info.Size()
 
-	fileBytes, err := ioutil.ReadAll(fileReader)
+	// Extract metadata and apply the privacy policy, only JPEG content is read in full
+	contents, err := m.prepareContents(media, file, m.metadataPolicyFor(collection, opts))
 	if err != nil {
-		m.logger.Error("Failed to read file: %v", err)
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		m.logger.Error("Failed to prepare file: %v", err)
+		return nil, err
 	}
+	m.logger.Debug("Detected mime type: %s for file size: %d bytes", media.MimeType, media.Size)
 
-	// Detect MIME type from content
-	contentReader := bytes.NewReader(fileBytes)
-	mimeType, err := getMimeTypeFromContent(contentReader)
+	// Save to DB first to get the ID
+	err = m.repository.Save(ctx, media)
 	if err != nil {
-		m.logger.Warning("Failed to detect MIME type from content: %v, falling back to extension-based detection", err)
-		mimeType = getMimeTypeFromExtension(filepath.Ext(media.FileName))
+		m.logger.Error("Failed to save media: %v", err)
+		return nil, fmt.Errorf("failed to save media: %w", err)
 	}
+	m.logger.Info("Successfully saved media ID %d", media.ID)
 
-	// Reset content reader for potential future use
-	contentReader.Seek(0, 0)
-
-	// Update the media with the actual file size and detected MIME type
-	media.Size = int64(len(fileBytes))
-	media.MimeType = mimeType
-	m.logger.Debug("Detected mime type: %s for file size: %d bytes", media.MimeType, media.Size)
-	media.UpdatedAt = time.Now()
+	// Now we have the ID, we can generate the proper path
+	path := m.pathGenerator.GetPath(media)
+	m.logger.Info("Saving media from URL %s to path %s", urlStr, path)
 
-	// Save the updated media with the correct file size
-	err = m.repository.Save(ctx, media)
+	err = disk.Save(ctx, path, contents,
+		storage.WithVisibility(media.GetVisibility()),
+		storage.WithContentType(media.MimeType))
 	if err != nil {
-		m.logger.Error("Failed to update media: %v", err)
-		return nil, fmt.Errorf("failed to update media: %w", err)
+		m.logger.Error("Failed to store file: %v", err)
+		return nil, fmt.Errorf("failed to store file: %w", err)
 	}
-	m.logger.Info("Successfully updated media ID %d with file size", media.ID)
 
-	if opts.AutoGenerateConversions && len(opts.PerformConversions) > 0 {
-		m.logger.Info("Performing %d conversions", len(opts.PerformConversions))
-		err = m.PerformConversions(ctx, media, opts.PerformConversions...)
-		if err != nil {
-			m.logger.Warning("Error performing conversions: %v", err)
-		}
+	var conversionNames []string
+	if opts.AutoGenerateConversions {
+		conversionNames = opts.PerformConversions
 	}
 
-	if len(opts.GenerateResponsiveImages) > 0 {
-		m.logger.Info("Generating responsive images for %d conversions", len(opts.GenerateResponsiveImages))
-		err = m.GenerateResponsiveImages(ctx, media, opts.GenerateResponsiveImages...)
+	if len(conversionNames) > 0 || len(opts.GenerateResponsiveImages) > 0 {
+		m.logger.Info("Performing %d conversions and responsive images for %d conversions", len(conversionNames), len(opts.GenerateResponsiveImages))
+		err = m.RunConversions(ctx, media, conversionNames, opts.GenerateResponsiveImages)
 		if err != nil {
-			m.logger.Warning("Error generating responsive images: %v", err)
+			m.logger.Warning("Error performing conversions: %v", err)
 		}
 	}
 
@@ -196,3 +202,50 @@ func (m *DefaultMediaLibrary) AddMediaFromURLToModel(
 
 	return m.AddMediaFromURL(ctx, urlStr, collection, options...)
 }
+
+// downloadToTempFile downloads a URL into a temporary file positioned at its start,
+// the caller closes and removes it. Bodies above maxSize fail with ErrDownloadTooLarge.
+func downloadToTempFile(ctx context.Context, client *http.Client, urlStr string, maxSize int64) (*os.File, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrDownloadTooLarge, resp.ContentLength)
+	}
+
+	file, err := os.CreateTemp("", "medialibrary-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	// One byte past the limit tells a body of exactly maxSize from a larger one
+	n, err := io.Copy(file, io.LimitReader(resp.Body, maxSize+1))
+	if err == nil && n > maxSize {
+		err = fmt.Errorf("%w: more than %d bytes", ErrDownloadTooLarge, maxSize)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	return file, nil
+}
@@ -0,0 +1,183 @@
+package medialibrary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/vortechron/go-medialibrary/models"
+)
+
+// ConversionStatus describes the conversions and responsive images of a media item
+type ConversionStatus struct {
+	Conversions      map[string]models.ConversionResult
+	ResponsiveImages map[string]map[int]models.ConversionResult
+	Counts           ConversionCounts
+}
+
+// ConversionCounts counts conversion entries by status
+type ConversionCounts struct {
+	Pending int `json:"pending"`
+	Done    int `json:"done"`
+	Failed  int `json:"failed"`
+}
+
+// add counts a single entry
+func (c *ConversionCounts) add(result models.ConversionResult) {
+	switch {
+	case result.Status == models.ConversionStatusFailed:
+		c.Failed++
+	case result.Status == models.ConversionStatusPending:
+		c.Pending++
+	case result.Done():
+		c.Done++
+	}
+}
+
+// ConversionFilter selects media for RetryFailedConversions and GetConversionCounts.
+// A collection, a model or both are required.
+type ConversionFilter struct {
+	ModelType  string
+	ModelID    uint64
+	Collection string
+	// MaxAttempts skips entries that already failed this many times, 0 retries them all
+	MaxAttempts int
+}
+
+// GetConversionStatus returns the status of every conversion and responsive image of the media
+func (m *DefaultMediaLibrary) GetConversionStatus(media *models.Media) (*ConversionStatus, error) {
+	conversions, err := media.GetGeneratedConversions()
+	if err != nil {
+		return nil, err
+	}
+
+	responsiveImages, err := media.GetResponsiveImages()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &ConversionStatus{
+		Conversions:      conversions,
+		ResponsiveImages: responsiveImages,
+	}
+
+	for _, result := range conversions {
+		status.Counts.add(result)
+	}
+	for _, widths := range responsiveImages {
+		for _, result := range widths {
+			status.Counts.add(result)
+		}
+	}
+
+	return status, nil
+}
+
+// GetConversionCounts returns the number of pending, generated and failed entries of the matching media
+func (m *DefaultMediaLibrary) GetConversionCounts(ctx context.Context, filter ConversionFilter) (ConversionCounts, error) {
+	var counts ConversionCounts
+
+	mediaList, err := m.findMediaForFilter(ctx, filter)
+	if err != nil {
+		return counts, err
+	}
+
+	for _, media := range mediaList {
+		status, err := m.GetConversionStatus(media)
+		if err != nil {
+			m.logger.Warning("Failed to read conversion status of media ID %d: %v", media.ID, err)
+			continue
+		}
+
+		counts.Pending += status.Counts.Pending
+		counts.Done += status.Counts.Done
+		counts.Failed += status.Counts.Failed
+	}
+
+	return counts, nil
+}
+
+// RetryFailedConversions regenerates the failed and interrupted conversions and responsive images
+// of the matching media. It returns the number of media items that were retried.
+func (m *DefaultMediaLibrary) RetryFailedConversions(ctx context.Context, filter ConversionFilter) (int, error) {
+	mediaList, err := m.findMediaForFilter(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	retried := 0
+	var errs []error
+
+	for _, media := range mediaList {
+		if err := ctx.Err(); err != nil {
+			return retried, err
+		}
+
+		status, err := m.GetConversionStatus(media)
+		if err != nil {
+			m.logger.Warning("Failed to read conversion status of media ID %d: %v", media.ID, err)
+			continue
+		}
+
+		var conversionNames []string
+		for name, result := range status.Conversions {
+			if shouldRetry(result, filter.MaxAttempts) {
+				conversionNames = append(conversionNames, name)
+			}
+		}
+
+		var responsiveNames []string
+		for name, widths := range status.ResponsiveImages {
+			for _, result := range widths {
+				if shouldRetry(result, filter.MaxAttempts) {
+					responsiveNames = append(responsiveNames, name)
+					break
+				}
+			}
+		}
+
+		if len(conversionNames) == 0 && len(responsiveNames) == 0 {
+			continue
+		}
+
+		m.logger.Info("Retrying conversions %v and responsive images %v for media ID %d", conversionNames, responsiveNames, media.ID)
+		retried++
+
+		if err := m.RunConversions(ctx, media, conversionNames, responsiveNames); err != nil {
+			m.logger.Warning("Retry failed for media ID %d: %v", media.ID, err)
+			errs = append(errs, fmt.Errorf("media ID %d: %w", media.ID, err))
+		}
+	}
+
+	return retried, errors.Join(errs...)
+}
+
+// shouldRetry reports whether an entry needs to be generated again
+func shouldRetry(result models.ConversionResult, maxAttempts int) bool {
+	if result.Done() {
+		return false
+	}
+	return maxAttempts <= 0 || result.Attempts < maxAttempts
+}
+
+// findMediaForFilter returns the media matching the filter using the optional repository finders
+func (m *DefaultMediaLibrary) findMediaForFilter(ctx context.Context, filter ConversionFilter) ([]*models.Media, error) {
+	switch {
+	case filter.ModelType != "" && filter.Collection != "":
+		return m.GetMediaForModelAndCollection(ctx, filter.ModelType, filter.ModelID, filter.Collection)
+	case filter.ModelType != "":
+		return m.GetMediaForModel(ctx, filter.ModelType, filter.ModelID)
+	case filter.Collection != "":
+		repo, ok := m.repository.(interface {
+			FindByCollection(ctx context.Context, collection string) ([]*models.Media, error)
+		})
+
+		if !ok {
+			return nil, fmt.Errorf("repository does not support FindByCollection")
+		}
+
+		return repo.FindByCollection(ctx, filter.Collection)
+	default:
+		return nil, fmt.Errorf("conversion filter requires a collection or model")
+	}
+}
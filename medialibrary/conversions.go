@@ -1,8 +1,9 @@
 package medialibrary
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	"image/gif"
@@ -10,16 +11,99 @@ import (
 	"image/png"
 	"io"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/vortechron/go-medialibrary/conversion"
+	"github.com/vortechron/go-medialibrary/generator"
 	"github.com/vortechron/go-medialibrary/models"
 	"github.com/vortechron/go-medialibrary/storage"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+	"golang.org/x/sync/semaphore"
 )
 
+// supportedInputFormats lists the MIME types that can be decoded for conversions
+var supportedInputFormats = []string{
+	"image/jpeg",
+	"image/png",
+	"image/gif",
+	"image/webp",
+	"image/bmp",
+	"image/tiff",
+}
+
+// SupportedInputFormats returns the MIME types of originals that conversions can be generated for,
+// including the types handled by registered image generators
+func (m *DefaultMediaLibrary) SupportedInputFormats() []string {
+	formats := make([]string, len(supportedInputFormats))
+	copy(formats, supportedInputFormats)
+
+	for _, mimeType := range m.generators.MimeTypes() {
+		if !slices.Contains(formats, mimeType) {
+			formats = append(formats, mimeType)
+		}
+	}
+
+	return formats
+}
+
+// supportsInputFormat reports whether conversions can be generated for the MIME type
+func (m *DefaultMediaLibrary) supportsInputFormat(mimeType string) bool {
+	if _, ok := m.generators.Get(mimeType); ok {
+		return true
+	}
+	return slices.Contains(supportedInputFormats, mimeType)
+}
+
+// RegisterImageGenerator registers a generator that turns originals of the MIME type into an
+// image before conversions run, for example to render PDFs or extract video frames
+func (m *DefaultMediaLibrary) RegisterImageGenerator(mimeType string, g generator.ImageGenerator) {
+	m.generators.Register(mimeType, g)
+}
+
 // PerformConversions performs the specified conversions on the media file
 func (m *DefaultMediaLibrary) PerformConversions(ctx context.Context, media *models.Media, conversionNames ...string) error {
-	m.logger.Info("Performing conversions for media ID %d: %v", media.ID, conversionNames)
+	return m.RunConversions(ctx, media, conversionNames, nil)
+}
+
+// GenerateResponsiveImages generates responsive images for the specified conversions
+func (m *DefaultMediaLibrary) GenerateResponsiveImages(ctx context.Context, media *models.Media, conversionNames ...string) error {
+	return m.RunConversions(ctx, media, nil, conversionNames)
+}
+
+// OriginalResponsiveSet is the reserved name of a responsive set that is always resized
+// straight from the original, even if a conversion of the same name is registered
+const OriginalResponsiveSet = "original"
+
+// conversionJob is a single image to generate in a conversion run
+type conversionJob struct {
+	conversionName string
+	width          int  // responsive width, 0 for regular conversions
+	direct         bool // resize the original instead of running the conversion
+	path           string
+	options        []conversion.Option
+	result         models.ConversionResult // previous entry while queued, outcome after the run
+}
+
+// RunConversions generates conversions and responsive images in a single run. The original is
+// downloaded and decoded once, the images are generated concurrently by a bounded worker pool,
+// and the generated conversions and responsive images are stored with one repository save.
+func (m *DefaultMediaLibrary) RunConversions(ctx context.Context, media *models.Media, conversionNames []string, responsiveNames []string) error {
+	m.logger.Info("Running conversions for media ID %d: conversions %v, responsive images %v", media.ID, conversionNames, responsiveNames)
+
+	if len(conversionNames) == 0 && len(responsiveNames) == 0 {
+		return nil
+	}
+
+	if !m.supportsInputFormat(media.MimeType) {
+		m.logger.Info("Skipping conversions for media ID %d: unsupported input format %s", media.ID, media.MimeType)
+		return nil
+	}
 
 	sourceDisk, err := m.diskManager.GetDisk(media.Disk)
 	if err != nil {
@@ -33,87 +117,120 @@ func (m *DefaultMediaLibrary) PerformConversions(ctx context.Context, media *mod
 		return fmt.Errorf("failed to get conversions disk %s: %w", media.ConversionsDisk, err)
 	}
 
-	sourcePath := m.pathGenerator.GetPath(media)
-	m.logger.Debug("Reading source file from path: %s", sourcePath)
-
-	fileReader, err := sourceDisk.Get(ctx, sourcePath)
+	generatedConversions, err := media.GetGeneratedConversions()
 	if err != nil {
-		m.logger.Error("Failed to get original file: %v", err)
-		return fmt.Errorf("failed to get original file: %w", err)
+		m.logger.Warning("Failed to read generated conversions, starting fresh: %v", err)
+		generatedConversions = make(map[string]models.ConversionResult)
 	}
-	defer fileReader.Close()
 
-	img, _, err := image.Decode(fileReader)
+	responsiveImages, err := media.GetResponsiveImages()
 	if err != nil {
-		m.logger.Error("Failed to decode image: %v", err)
-		return fmt.Errorf("failed to decode image: %w", err)
+		m.logger.Warning("Failed to read responsive images, starting fresh: %v", err)
+		responsiveImages = make(map[string]map[int]models.ConversionResult)
 	}
 
-	generatedConversions := make(map[string]bool)
-
-	if media.GeneratedConversions != nil && len(media.GeneratedConversions) > 0 {
-		err = json.Unmarshal(media.GeneratedConversions, &generatedConversions)
-		if err != nil {
-			m.logger.Warning("Failed to unmarshal generated conversions, starting fresh: %v", err)
-			generatedConversions = make(map[string]bool)
-		}
-	}
+	baseOptions := m.conversionOptionsForMedia(media)
+	var jobs []conversionJob
 
 	for _, conversionName := range conversionNames {
-		m.logger.Debug("Processing conversion: %s", conversionName)
-
-		if generatedConversions[conversionName] {
+		previous, exists := generatedConversions[conversionName]
+		if exists && previous.Done() {
 			m.logger.Debug("Conversion %s already exists, skipping", conversionName)
 			continue
 		}
 
-		transformed, err := m.transformer.Transform(ctx, img, conversionName)
-		if err != nil {
-			m.logger.Warning("Error transforming image for conversion %s: %v", conversionName, err)
-			continue
-		}
+		jobs = append(jobs, conversionJob{
+			conversionName: conversionName,
+			path:           m.pathGenerator.GetPathForConversion(media, conversionName),
+			options:        baseOptions,
+			result:         previous,
+		})
+	}
 
-		conversionPath := m.pathGenerator.GetPathForConversion(media, conversionName)
-		m.logger.Debug("Saving conversion to path: %s", conversionPath)
+	if len(responsiveNames) > 0 {
+		responsiveConversions := m.transformer.GetResponsiveImageConversions()
+		registeredConversions := m.transformer.GetRegisteredConversions()
+		m.logger.Debug("Available responsive conversions: %v", getMapKeys(responsiveConversions))
 
-		pr, pw := io.Pipe()
-		go func() {
-			var encodeErr error
-			switch filepath.Ext(media.FileName) {
-			case ".png":
-				encodeErr = png.Encode(pw, transformed)
-			case ".gif":
-				encodeErr = gif.Encode(pw, transformed, nil)
-			default:
-				encodeErr = jpeg.Encode(pw, transformed, &jpeg.Options{Quality: 90})
+		for _, conversionName := range responsiveNames {
+			responsiveConversion, exists := responsiveConversions[conversionName]
+			if !exists {
+				m.logger.Warning("Responsive conversion %s not found in transformer", conversionName)
+				continue
 			}
 
-			if encodeErr != nil {
-				pw.CloseWithError(encodeErr)
-				return
+			if responsiveImages[conversionName] == nil {
+				responsiveImages[conversionName] = make(map[int]models.ConversionResult)
 			}
-			pw.Close()
-		}()
 
-		err = conversionsDisk.Save(ctx, conversionPath, pr,
-			storage.WithVisibility("public"),
-			storage.WithContentType(media.MimeType))
+			// Sets without a conversion of the same name are resized from the original
+			_, hasConversion := registeredConversions[conversionName]
+			direct := conversionName == OriginalResponsiveSet || !hasConversion
+
+			for _, width := range responsiveConversion.Widths {
+				previous, exists := responsiveImages[conversionName][width]
+				if exists && previous.Done() {
+					m.logger.Debug("Responsive image for %s at width %d already exists, skipping", conversionName, width)
+					continue
+				}
+
+				// Registered options first, the media keeps control of the format and focal point
+				options := []conversion.Option{conversion.WithOptions(responsiveConversion.Options)}
+				options = append(options, baseOptions...)
+				options = append(options, conversion.WithWidth(width))
+
+				jobs = append(jobs, conversionJob{
+					conversionName: conversionName,
+					width:          width,
+					direct:         direct,
+					path:           m.pathGenerator.GetPathForResponsiveImage(media, conversionName, width),
+					options:        options,
+					result:         previous,
+				})
+			}
+		}
+	}
+
+	for i := range jobs {
+		jobs[i].result.Status = models.ConversionStatusPending
+	}
+
+	var runErr error
+	if len(jobs) > 0 {
+		source, err := m.loadSource(ctx, sourceDisk, media)
 		if err != nil {
-			m.logger.Warning("Error storing converted image for %s: %v", conversionName, err)
-			continue
+			// Record the failure on every job so the media shows up as failed
+			runErr = err
+			for i := range jobs {
+				jobs[i].result = failedResult(jobs[i].result, err)
+			}
+		} else {
+			m.runConversionJobs(ctx, source, media, conversionsDisk, jobs)
 		}
 
-		generatedConversions[conversionName] = true
-		m.logger.Info("Successfully generated conversion: %s", conversionName)
+		for _, job := range jobs {
+			if job.width > 0 {
+				responsiveImages[job.conversionName][job.width] = job.result
+			} else {
+				generatedConversions[job.conversionName] = job.result
+			}
+		}
 	}
 
-	generatedConversionsBytes, err := json.Marshal(generatedConversions)
-	if err != nil {
-		m.logger.Error("Failed to marshal generated conversions: %v", err)
-		return fmt.Errorf("failed to marshal generated conversions: %w", err)
+	if len(conversionNames) > 0 {
+		if err := media.SetGeneratedConversions(generatedConversions); err != nil {
+			m.logger.Error("Failed to store generated conversions: %v", err)
+			return err
+		}
+	}
+
+	if len(responsiveNames) > 0 {
+		if err := media.SetResponsiveImages(responsiveImages); err != nil {
+			m.logger.Error("Failed to store responsive images: %v", err)
+			return err
+		}
 	}
 
-	media.GeneratedConversions = generatedConversionsBytes
 	media.UpdatedAt = time.Now()
 
 	err = m.repository.Save(ctx, media)
@@ -122,138 +239,396 @@ func (m *DefaultMediaLibrary) PerformConversions(ctx context.Context, media *mod
 		return fmt.Errorf("failed to save media: %w", err)
 	}
 
-	m.logger.Info("Completed performing conversions for media ID %d", media.ID)
+	if runErr != nil {
+		return runErr
+	}
+
+	m.logger.Info("Completed running conversions for media ID %d", media.ID)
 	return nil
 }
 
-// GenerateResponsiveImages generates responsive images for the specified conversions
-func (m *DefaultMediaLibrary) GenerateResponsiveImages(ctx context.Context, media *models.Media, conversionNames ...string) error {
-	m.logger.Info("Generating responsive images for media ID %d: %v", media.ID, conversionNames)
+// runConversionJobs generates the images on a pool of workers and records the outcome in the
+// result of every job. Jobs that were not started because the context was cancelled stay pending.
+// Each job reserves the pixel count of the source from the memory budget while it runs.
+func (m *DefaultMediaLibrary) runConversionJobs(ctx context.Context, source *sourceImage, media *models.Media, disk storage.Storage, jobs []conversionJob) {
+	workers := m.defaultOptions.ConversionWorkers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(jobs))
 
-	sourceDisk, err := m.diskManager.GetDisk(media.Disk)
+	weight := source.pixels()
+	var budget *semaphore.Weighted
+	if m.defaultOptions.ConversionMemoryBudget > 0 {
+		weight = min(weight, m.defaultOptions.ConversionMemoryBudget)
+		budget = semaphore.NewWeighted(m.defaultOptions.ConversionMemoryBudget)
+	}
+
+	m.logger.Debug("Running %d conversion jobs on %d workers", len(jobs), workers)
+
+	// Workers only write to the job at the index they receive
+	queue := make(chan int)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range queue {
+				job := &jobs[index]
+
+				if budget != nil {
+					if err := budget.Acquire(ctx, weight); err != nil {
+						m.logger.Warning("Skipping %s: %v", job.describe(), err)
+						continue
+					}
+				}
+
+				result, err := m.runConversionJob(ctx, source, media, disk, *job)
+
+				if budget != nil {
+					budget.Release(weight)
+				}
+
+				if err != nil {
+					if errors.Is(err, conversion.ErrConversionTimeout) {
+						m.logger.Warning("Timed out generating %s: %v", job.describe(), err)
+					} else {
+						m.logger.Warning("Error generating %s: %v", job.describe(), err)
+					}
+					job.result = failedResult(job.result, err)
+					continue
+				}
+
+				result.Status = models.ConversionStatusDone
+				result.Attempts = job.result.Attempts + 1
+				job.result = *result
+				m.logger.Info("Successfully generated %s", job.describe())
+			}
+		}()
+	}
+
+	for i := range jobs {
+		if ctx.Err() != nil {
+			m.logger.Warning("Conversion run cancelled: %v", ctx.Err())
+			break
+		}
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// failedResult records a failed attempt on top of the previous entry
+func failedResult(previous models.ConversionResult, err error) models.ConversionResult {
+	previous.Status = models.ConversionStatusFailed
+	previous.Error = err.Error()
+	previous.Attempts++
+	return previous
+}
+
+// runConversionJob transforms the source for a single job and stores the result
+func (m *DefaultMediaLibrary) runConversionJob(ctx context.Context, source *sourceImage, media *models.Media, disk storage.Storage, job conversionJob) (*models.ConversionResult, error) {
+	var converted *convertedImage
+	var err error
+	if job.direct {
+		converted, err = m.resizeSource(ctx, source, job.conversionName, job.options...)
+	} else {
+		converted, err = m.transformSource(ctx, source, media, job.conversionName, job.options...)
+	}
 	if err != nil {
-		m.logger.Error("Failed to get source disk %s: %v", media.Disk, err)
-		return fmt.Errorf("failed to get source disk %s: %w", media.Disk, err)
+		return nil, fmt.Errorf("failed to transform image: %w", err)
 	}
 
-	conversionsDisk, err := m.diskManager.GetDisk(media.ConversionsDisk)
+	m.logger.Debug("Saving %s to path: %s", job.describe(), job.path)
+
+	result, err := m.saveConvertedImage(ctx, disk, job.path, converted, media)
 	if err != nil {
-		m.logger.Error("Failed to get conversions disk %s: %v", media.ConversionsDisk, err)
-		return fmt.Errorf("failed to get conversions disk %s: %w", media.ConversionsDisk, err)
+		return nil, fmt.Errorf("failed to store converted image: %w", err)
+	}
+
+	return result, nil
+}
+
+// describe returns a readable name of the job for logging
+func (j conversionJob) describe() string {
+	if j.width > 0 {
+		return fmt.Sprintf("responsive image %s at width %d", j.conversionName, j.width)
 	}
+	return fmt.Sprintf("conversion %s", j.conversionName)
+}
 
+// sourceImage is a decoded original. For animated GIFs all frames are kept in animation.
+type sourceImage struct {
+	image     image.Image
+	animation *gif.GIF
+}
+
+// pixels returns the number of decoded pixels held by the source
+func (s *sourceImage) pixels() int64 {
+	bounds := s.image.Bounds()
+	pixels := int64(bounds.Dx()) * int64(bounds.Dy())
+	if s.animation != nil {
+		pixels *= int64(len(s.animation.Image))
+	}
+	return pixels
+}
+
+// convertedImage is the result of a conversion, either a still image or an animation.
+// Format and quality are the encoding settings chosen by the conversion, if any.
+type convertedImage struct {
+	image     image.Image
+	animation *gif.GIF
+	format    string
+	quality   int
+}
+
+// bounds returns the size of the converted image or animation
+func (c *convertedImage) bounds() image.Rectangle {
+	if c.animation != nil {
+		return image.Rect(0, 0, c.animation.Config.Width, c.animation.Config.Height)
+	}
+	return c.image.Bounds()
+}
+
+// loadSource downloads and decodes the original file of the media
+func (m *DefaultMediaLibrary) loadSource(ctx context.Context, sourceDisk storage.Storage, media *models.Media) (*sourceImage, error) {
 	sourcePath := m.pathGenerator.GetPath(media)
 	m.logger.Debug("Reading source file from path: %s", sourcePath)
 
 	fileReader, err := sourceDisk.Get(ctx, sourcePath)
 	if err != nil {
 		m.logger.Error("Failed to get original file: %v", err)
-		return fmt.Errorf("failed to get original file: %w", err)
+		return nil, fmt.Errorf("failed to get original file: %w", err)
 	}
 	defer fileReader.Close()
 
-	img, _, err := image.Decode(fileReader)
+	content, err := io.ReadAll(fileReader)
 	if err != nil {
-		m.logger.Error("Failed to decode image: %v", err)
-		return fmt.Errorf("failed to decode image: %w", err)
+		m.logger.Error("Failed to read original file: %v", err)
+		return nil, fmt.Errorf("failed to read original file: %w", err)
 	}
 
-	responsiveImages := make(map[string]map[string]bool)
+	if g, ok := m.generators.Get(media.MimeType); ok {
+		m.logger.Debug("Generating image from %s original", media.MimeType)
 
-	if media.ResponsiveImages != nil && len(media.ResponsiveImages) > 0 {
-		err = json.Unmarshal(media.ResponsiveImages, &responsiveImages)
+		img, err := g.Generate(ctx, bytes.NewReader(content))
 		if err != nil {
-			m.logger.Warning("Failed to unmarshal responsive images, starting fresh: %v", err)
-			responsiveImages = make(map[string]map[string]bool)
+			m.logger.Error("Failed to generate image from %s: %v", media.MimeType, err)
+			return nil, fmt.Errorf("failed to generate image: %w", err)
 		}
+
+		return &sourceImage{image: img}, nil
 	}
 
-	responsiveConversions := m.transformer.GetResponsiveImageConversions()
-	m.logger.Debug("Available responsive conversions: %v", getMapKeys(responsiveConversions))
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		m.logger.Error("Failed to decode image: %v", err)
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
 
-	for _, conversionName := range conversionNames {
-		responsiveConversion, exists := responsiveConversions[conversionName]
-		if !exists {
-			m.logger.Warning("Responsive conversion %s not found in transformer", conversionName)
-			continue
+	source := &sourceImage{image: img}
+
+	if media.MimeType == "image/gif" {
+		animation, err := gif.DecodeAll(bytes.NewReader(content))
+		if err != nil {
+			m.logger.Warning("Failed to decode GIF frames, using first frame only: %v", err)
+		} else if len(animation.Image) > 1 {
+			m.logger.Debug("Decoded animated GIF with %d frames", len(animation.Image))
+			source.animation = animation
 		}
+	}
+
+	return source, nil
+}
 
-		m.logger.Debug("Processing responsive images for conversion: %s", conversionName)
+// loadOriginalImage downloads and decodes the original file of the media as a still image
+func (m *DefaultMediaLibrary) loadOriginalImage(ctx context.Context, sourceDisk storage.Storage, media *models.Media) (image.Image, error) {
+	source, err := m.loadSource(ctx, sourceDisk, media)
+	if err != nil {
+		return nil, err
+	}
+
+	return source.image, nil
+}
 
-		if responsiveImages[conversionName] == nil {
-			responsiveImages[conversionName] = make(map[string]bool)
+// transformSource runs a conversion on the source. Animated GIFs are converted frame by frame
+// unless the conversion is configured to produce a static poster.
+func (m *DefaultMediaLibrary) transformSource(ctx context.Context, source *sourceImage, media *models.Media, conversionName string, options ...conversion.Option) (*convertedImage, error) {
+	if source.animation != nil && !m.isGIFPosterConversion(conversionName) {
+		animation, err := conversion.TransformGIF(ctx, m.transformer, source.animation, conversionName, options...)
+		if err != nil {
+			return nil, err
 		}
+		return &convertedImage{animation: animation}, nil
+	}
 
-		for _, width := range responsiveConversion.Widths {
-			widthKey := fmt.Sprintf("%d", width)
-			if responsiveImages[conversionName][widthKey] {
-				m.logger.Debug("Responsive image for %s at width %d already exists, skipping", conversionName, width)
-				continue
-			}
+	transformed, opts, err := m.transformer.TransformWithOptions(ctx, source.image, conversionName, options...)
+	if err != nil {
+		return nil, err
+	}
 
-			m.logger.Debug("Generating responsive image for %s at width %d", conversionName, width)
+	return &convertedImage{image: transformed, format: opts.Format, quality: opts.Quality}, nil
+}
 
-			opts := responsiveConversion.Options
-			opts.Width = width
+// resizeSource resizes the source with the options alone, without running a registered conversion
+func (m *DefaultMediaLibrary) resizeSource(ctx context.Context, source *sourceImage, conversionName string, options ...conversion.Option) (*convertedImage, error) {
+	// Frames of an animation share the crop window of the first frame
+	opts := conversion.NewOptions(append(options[:len(options):len(options)], conversion.WithSharedCrop())...)
 
-			transformed, err := m.transformer.Transform(ctx, img, conversionName, conversion.WithWidth(width))
-			if err != nil {
-				m.logger.Warning("Error generating responsive image for %s width %d: %v", conversionName, width, err)
-				continue
-			}
+	resize := func(ctx context.Context, img image.Image) (image.Image, error) {
+		frameOpts := *opts
+		return m.transformer.ResizeImage(ctx, img, opts.Width, opts.Height, &frameOpts)
+	}
 
-			responsivePath := m.pathGenerator.GetPathForResponsiveImage(media, conversionName, width)
-			m.logger.Debug("Saving responsive image to path: %s", responsivePath)
-
-			pr, pw := io.Pipe()
-			go func() {
-				var encodeErr error
-				switch filepath.Ext(media.FileName) {
-				case ".png":
-					encodeErr = png.Encode(pw, transformed)
-				case ".gif":
-					encodeErr = gif.Encode(pw, transformed, nil)
-				default:
-					encodeErr = jpeg.Encode(pw, transformed, &jpeg.Options{Quality: 90})
-				}
+	if source.animation != nil && !m.isGIFPosterConversion(conversionName) {
+		animation, err := conversion.TransformGIFFunc(ctx, source.animation, resize)
+		if err != nil {
+			return nil, err
+		}
+		return &convertedImage{animation: animation}, nil
+	}
 
-				if encodeErr != nil {
-					pw.CloseWithError(encodeErr)
-					return
-				}
-				pw.Close()
-			}()
-
-			err = conversionsDisk.Save(ctx, responsivePath, pr,
-				storage.WithVisibility("public"),
-				storage.WithContentType(media.MimeType))
-			if err != nil {
-				m.logger.Warning("Error storing responsive image for %s width %d: %v", conversionName, width, err)
-				continue
-			}
+	resized, err := resize(ctx, source.image)
+	if err != nil {
+		return nil, err
+	}
+
+	return &convertedImage{image: resized, format: opts.Format, quality: opts.Quality}, nil
+}
 
-			responsiveImages[conversionName][widthKey] = true
-			m.logger.Info("Successfully generated responsive image: %s at width %d", conversionName, width)
+// isGIFPosterConversion reports whether the conversion should emit a static first frame for animated GIFs
+func (m *DefaultMediaLibrary) isGIFPosterConversion(conversionName string) bool {
+	for _, name := range m.defaultOptions.GIFPosterConversions {
+		if name == conversionName {
+			return true
 		}
 	}
+	return false
+}
+
+// saveConvertedImage encodes the converted image in the format chosen by the conversion,
+// or the output format of the media, stores it and describes the stored file
+func (m *DefaultMediaLibrary) saveConvertedImage(ctx context.Context, disk storage.Storage, path string, converted *convertedImage, media *models.Media) (*models.ConversionResult, error) {
+	pr, pw := io.Pipe()
+
+	format := outputFormat(media.FileName)
+	if converted.animation != nil {
+		format = "gif"
+	} else if converted.format != "" {
+		var err error
+		if format, err = conversion.OutputFormat(converted.format); err != nil {
+			return nil, err
+		}
+	}
+	path = formatPath(path, format)
+
+	quality := converted.quality
+	if quality <= 0 {
+		quality = 90
+	}
+
+	go func() {
+		var encodeErr error
+		switch {
+		case converted.animation != nil:
+			encodeErr = gif.EncodeAll(pw, converted.animation)
+		case format == "png":
+			encodeErr = png.Encode(pw, converted.image)
+		case format == "gif":
+			encodeErr = gif.Encode(pw, converted.image, nil)
+		case format == "bmp":
+			encodeErr = bmp.Encode(pw, converted.image)
+		case format == "tiff":
+			encodeErr = tiff.Encode(pw, converted.image, &tiff.Options{Compression: tiff.Deflate})
+		case format == "jpg":
+			encodeErr = jpeg.Encode(pw, converted.image, &jpeg.Options{Quality: quality})
+		default:
+			encodeErr = fmt.Errorf("no encoder for format %q", format)
+		}
+
+		if encodeErr != nil {
+			pw.CloseWithError(encodeErr)
+			return
+		}
+		pw.Close()
+	}()
+
+	counter := &countingReader{reader: pr}
+	err := disk.Save(ctx, path, counter,
+		storage.WithVisibility(media.GetVisibility()),
+		storage.WithContentType(conversion.FormatMimeType(format)))
+
+	// Unblock the encoder in case the disk stopped reading early
+	pr.Close()
 
-	responsiveImagesBytes, err := json.Marshal(responsiveImages)
 	if err != nil {
-		m.logger.Error("Failed to marshal responsive images: %v", err)
-		return fmt.Errorf("failed to marshal responsive images: %w", err)
+		return nil, err
 	}
 
-	media.ResponsiveImages = responsiveImagesBytes
-	media.UpdatedAt = time.Now()
+	bounds := converted.bounds()
+	return &models.ConversionResult{
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+		Size:        counter.count,
+		Format:      format,
+		MimeType:    conversion.FormatMimeType(format),
+		GeneratedAt: time.Now(),
+	}, nil
+}
 
-	err = m.repository.Save(ctx, media)
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// conversionOptionsForMedia returns the transformer options derived from the media record
+func (m *DefaultMediaLibrary) conversionOptionsForMedia(media *models.Media) []conversion.Option {
+	// Let the transformer know the output format so it can flatten transparency when needed
+	options := []conversion.Option{conversion.WithFormat(outputFormat(media.FileName))}
+
+	focalPoint, err := getFocalPoint(media)
 	if err != nil {
-		m.logger.Error("Failed to save media with updated responsive images: %v", err)
-		return fmt.Errorf("failed to save media: %w", err)
+		m.logger.Warning("Ignoring focal point of media ID %d: %v", media.ID, err)
+	} else if focalPoint != nil {
+		options = append(options, conversion.WithFocalPoint(focalPoint.X, focalPoint.Y))
 	}
 
-	m.logger.Info("Completed generating responsive images for media ID %d", media.ID)
-	return nil
+	return options
+}
+
+// outputFormat returns the format conversions of the file are encoded in, the format of
+// its extension when there is an encoder for it. WebP falls back to PNG and SVGs are
+// rasterized to PNG to keep transparency. Originals handled by other generators are
+// converted to JPEG.
+func outputFormat(fileName string) string {
+	ext := filepath.Ext(fileName)
+	if strings.EqualFold(ext, ".svg") {
+		return "png"
+	}
+
+	if format, err := conversion.OutputFormat(ext); err == nil {
+		return format
+	}
+	return "jpg"
+}
+
+// formatPath gives a conversion path the extension of the format it is encoded in, the
+// path generator keeps the extension of the original. Paths already ending in an
+// extension of the format and results without a recorded format are kept.
+func formatPath(path string, format string) string {
+	ext := filepath.Ext(path)
+	if format == "" || conversion.NormalizeFormat(ext) == format {
+		return path
+	}
+	return strings.TrimSuffix(path, ext) + "." + format
 }
 
 // Helper function to get map keys for logging
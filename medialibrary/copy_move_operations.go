@@ -1,12 +1,9 @@
 package medialibrary
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io/ioutil"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -47,25 +44,12 @@ func (m *DefaultMediaLibrary) CopyMediaToDisk(
 		return nil, fmt.Errorf("file does not exist on disk %s", media.Disk)
 	}
 
-	fileReader, err := sourceDiskStorage.Get(ctx, sourcePath)
-	if err != nil {
-		m.logger.Error("Failed to get file: %v", err)
-		return nil, fmt.Errorf("failed to get file: %w", err)
-	}
-	defer fileReader.Close()
-
 	id, err := uuid.NewV4()
 	if err != nil {
 		m.logger.Error("Failed to generate UUID: %v", err)
 		return nil, fmt.Errorf("failed to generate uuid: %w", err)
 	}
 
-	fileContent, err := ioutil.ReadAll(fileReader)
-	if err != nil {
-		m.logger.Error("Failed to read file: %v", err)
-		return nil, fmt.Errorf("failed to read file: %w", err)
-	}
-
 	copiedMedia := &models.Media{
 		ModelType:            media.ModelType,
 		ModelID:              media.ModelID,
@@ -76,11 +60,14 @@ func (m *DefaultMediaLibrary) CopyMediaToDisk(
 		MimeType:             media.MimeType,
 		Disk:                 targetDisk,
 		ConversionsDisk:      media.ConversionsDisk,
-		Size:                 int64(len(fileContent)),
+		Visibility:           media.Visibility,
+		Size:                 media.Size,
 		Manipulations:        media.Manipulations,
 		CustomProperties:     media.CustomProperties,
 		GeneratedConversions: media.GeneratedConversions,
 		ResponsiveImages:     media.ResponsiveImages,
+		Metadata:             media.Metadata,
+		FocalPoint:           media.FocalPoint,
 		OrderColumn:          media.OrderColumn,
 		CreatedAt:            time.Now(),
 		UpdatedAt:            time.Now(),
@@ -97,9 +84,10 @@ func (m *DefaultMediaLibrary) CopyMediaToDisk(
 	targetPath := m.pathGenerator.GetPath(copiedMedia)
 	m.logger.Info("Copying media to target path: %s", targetPath)
 
-	// Save to disk
-	err = targetDiskStorage.Save(ctx, targetPath, strings.NewReader(string(fileContent)),
-		storage.WithVisibility("public"))
+	// Copy on the storage backend when possible, streaming otherwise
+	err = storage.Copy(ctx, sourceDiskStorage, sourcePath, targetDiskStorage, targetPath,
+		storage.WithVisibility(copiedMedia.GetVisibility()),
+		storage.WithContentType(copiedMedia.MimeType))
 	if err != nil {
 		m.logger.Error("Failed to store file: %v", err)
 		return nil, fmt.Errorf("failed to store file: %w", err)
@@ -139,36 +127,17 @@ func (m *DefaultMediaLibrary) MoveMediaToDisk(ctx context.Context, media *models
 		return nil, fmt.Errorf("file does not exist on disk %s", media.Disk)
 	}
 
-	fileReader, err := sourceDiskStorage.Get(ctx, sourcePath)
-	if err != nil {
-		m.logger.Error("Failed to get file: %v", err)
-		return nil, fmt.Errorf("failed to get file: %w", err)
-	}
-	defer fileReader.Close()
-
 	id, err := uuid.NewV4()
 	if err != nil {
 		m.logger.Error("Failed to generate UUID: %v", err)
 		return nil, fmt.Errorf("failed to generate uuid: %w", err)
 	}
 
-	fileContent, err := ioutil.ReadAll(fileReader)
-	if err != nil {
-		m.logger.Error("Failed to read file: %v", err)
-		return nil, fmt.Errorf("failed to read file: %w", err)
-	}
-
-	// Detect MIME type from content
-	contentReader := bytes.NewReader(fileContent)
-	mimeType, err := getMimeTypeFromContent(contentReader)
-	if err != nil {
-		m.logger.Warning("Failed to detect MIME type from content: %v, falling back to extension-based detection", err)
+	mimeType := media.MimeType
+	if mimeType == "" {
 		mimeType = getMimeTypeFromExtension(filepath.Ext(media.FileName))
 	}
 
-	// Reset content reader for potential future use
-	contentReader.Seek(0, 0)
-
 	movedMedia := &models.Media{
 		ModelType:            media.ModelType,
 		ModelID:              media.ModelID,
@@ -179,11 +148,14 @@ func (m *DefaultMediaLibrary) MoveMediaToDisk(ctx context.Context, media *models
 		MimeType:             mimeType,
 		Disk:                 targetDisk,
 		ConversionsDisk:      media.ConversionsDisk,
-		Size:                 int64(len(fileContent)),
+		Visibility:           media.Visibility,
+		Size:                 media.Size,
 		Manipulations:        media.Manipulations,
 		CustomProperties:     media.CustomProperties,
 		GeneratedConversions: media.GeneratedConversions,
 		ResponsiveImages:     media.ResponsiveImages,
+		Metadata:             media.Metadata,
+		FocalPoint:           media.FocalPoint,
 		OrderColumn:          media.OrderColumn,
 		CreatedAt:            time.Now(),
 		UpdatedAt:            time.Now(),
@@ -200,23 +172,17 @@ func (m *DefaultMediaLibrary) MoveMediaToDisk(ctx context.Context, media *models
 	targetPath := m.pathGenerator.GetPath(movedMedia)
 	m.logger.Info("Moving media to target path: %s", targetPath)
 
-	// Save to the target disk
-	err = targetDiskStorage.Save(ctx, targetPath, strings.NewReader(string(fileContent)),
-		storage.WithVisibility("public"))
+	// Move on the storage backend when possible, otherwise stream and delete the original
+	err = storage.Move(ctx, sourceDiskStorage, sourcePath, targetDiskStorage, targetPath,
+		storage.WithVisibility(movedMedia.GetVisibility()),
+		storage.WithContentType(movedMedia.MimeType))
 	if err != nil {
-		m.logger.Error("Failed to store file: %v", err)
-		return nil, fmt.Errorf("failed to store file: %w", err)
+		m.logger.Error("Failed to move file: %v", err)
+		return nil, fmt.Errorf("failed to move file: %w", err)
 	}
 
 	m.logger.Debug("Moved media with mime type: %s size: %d bytes", movedMedia.MimeType, movedMedia.Size)
-
-	// Delete from the source disk
-	err = sourceDiskStorage.Delete(ctx, sourcePath)
-	if err != nil {
-		m.logger.Error("Failed to delete original file: %v", err)
-		return nil, fmt.Errorf("failed to delete file: %w", err)
-	}
-	m.logger.Info("Successfully deleted original file from disk %s path %s", media.Disk, sourcePath)
+	m.logger.Info("Successfully moved original file from disk %s path %s", media.Disk, sourcePath)
 
 	return movedMedia, nil
 }
@@ -0,0 +1,118 @@
+package medialibrary
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/vortechron/go-medialibrary/models"
+	"github.com/vortechron/go-medialibrary/storage"
+)
+
+// DeleteMedia removes the files of a media item from its disks and deletes its record
+func (m *DefaultMediaLibrary) DeleteMedia(ctx context.Context, media *models.Media) error {
+	m.logger.Debug("Deleting media ID %d", media.ID)
+
+	dir := m.mediaDirectory(media)
+
+	for _, diskName := range mediaDisks(media) {
+		disk, err := m.diskManager.GetDisk(diskName)
+		if err != nil {
+			m.logger.Error("Failed to get disk %s: %v", diskName, err)
+			return fmt.Errorf("failed to get disk %s: %w", diskName, err)
+		}
+
+		if err := disk.DeleteDirectory(ctx, dir); err != nil {
+			m.logger.Error("Failed to delete files of media ID %d from disk %s: %v", media.ID, diskName, err)
+			return fmt.Errorf("failed to delete media files: %w", err)
+		}
+	}
+
+	if err := m.repository.Delete(ctx, media); err != nil {
+		m.logger.Error("Failed to delete media record: %v", err)
+		return fmt.Errorf("failed to delete media record: %w", err)
+	}
+
+	m.logger.Info("Successfully deleted media ID %d", media.ID)
+	return nil
+}
+
+// ClearConversions removes every generated conversion, responsive image and cached
+// on-the-fly image of a media item and resets its conversion results
+func (m *DefaultMediaLibrary) ClearConversions(ctx context.Context, media *models.Media) error {
+	disk, err := m.diskManager.GetDisk(media.ConversionsDisk)
+	if err != nil {
+		m.logger.Error("Failed to get conversions disk %s: %v", media.ConversionsDisk, err)
+		return fmt.Errorf("failed to get conversions disk %s: %w", media.ConversionsDisk, err)
+	}
+
+	// Conversions live in sub directories of the media directory, the original sits next to them
+	objects, err := listObjects(ctx, disk, m.mediaDirectory(media), false)
+	if err != nil {
+		m.logger.Error("Failed to list conversions of media ID %d: %v", media.ID, err)
+		return fmt.Errorf("failed to list conversions: %w", err)
+	}
+
+	var dirs []string
+	for _, object := range objects {
+		if object.IsDir {
+			dirs = append(dirs, object.Path)
+		}
+	}
+
+	for _, dir := range dirs {
+		if err := disk.DeleteDirectory(ctx, dir); err != nil {
+			m.logger.Error("Failed to delete conversions directory %s: %v", dir, err)
+			return fmt.Errorf("failed to delete conversions directory %s: %w", dir, err)
+		}
+	}
+
+	if err := media.SetGeneratedConversions(map[string]models.ConversionResult{}); err != nil {
+		return err
+	}
+
+	if err := media.SetResponsiveImages(map[string]map[int]models.ConversionResult{}); err != nil {
+		return err
+	}
+
+	if err := m.repository.Save(ctx, media); err != nil {
+		m.logger.Error("Failed to save media after clearing conversions: %v", err)
+		return fmt.Errorf("failed to save media: %w", err)
+	}
+
+	m.logger.Info("Cleared %d conversion directories of media ID %d", len(dirs), media.ID)
+	return nil
+}
+
+// mediaDirectory returns the directory holding the original file and the conversions of a media item
+func (m *DefaultMediaLibrary) mediaDirectory(media *models.Media) string {
+	return filepath.ToSlash(filepath.Dir(m.pathGenerator.GetPath(media)))
+}
+
+// mediaDisks returns the distinct disks a media item has files on
+func mediaDisks(media *models.Media) []string {
+	if media.ConversionsDisk == "" || media.ConversionsDisk == media.Disk {
+		return []string{media.Disk}
+	}
+	return []string{media.Disk, media.ConversionsDisk}
+}
+
+// listObjects returns the objects below the prefix, following every page
+func listObjects(ctx context.Context, disk storage.Storage, prefix string, recursive bool) ([]storage.ObjectInfo, error) {
+	var objects []storage.ObjectInfo
+	var token string
+
+	for {
+		page, err := disk.List(ctx, prefix, recursive, storage.WithPageToken(token))
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, page.Objects...)
+
+		if page.NextPageToken == "" {
+			return objects, nil
+		}
+		token = page.NextPageToken
+	}
+}
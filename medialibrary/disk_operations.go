@@ -1,11 +1,10 @@
 package medialibrary
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -70,24 +69,18 @@ func (m *DefaultMediaLibrary) AddMediaFromDisk(
 		return nil, fmt.Errorf("failed to get disk %s: %w", diskName, err)
 	}
 
-	// Read file content first to get size
-	fileContent, err := ioutil.ReadAll(file)
+	info, err := file.Stat()
 	if err != nil {
-		m.logger.Error("Failed to read file: %v", err)
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		m.logger.Error("Failed to stat file: %v", err)
+		return nil, fmt.Errorf("failed to stat file: %w", err)
 	}
 
-	// Detect MIME type from content
-	contentReader := bytes.NewReader(fileContent)
-	mimeType, err := getMimeTypeFromContent(contentReader)
+	visibility, err := m.visibilityFor(collection, opts)
 	if err != nil {
-		m.logger.Warning("Failed to detect MIME type from content: %v, falling back to extension-based detection", err)
-		mimeType = getMimeTypeFromExtension(filepath.Ext(baseName))
+		m.logger.Error("Failed to resolve visibility: %v", err)
+		return nil, err
 	}
 
-	// Reset content reader for potential future use
-	contentReader.Seek(0, 0)
-
 	media := &models.Media{
 		ModelType:            opts.ModelType,
 		ModelID:              opts.ModelID,
@@ -97,12 +90,13 @@ func (m *DefaultMediaLibrary) AddMediaFromDisk(
 		FileName:             baseName,
 		Disk:                 diskName,
 		ConversionsDisk:      opts.ConversionsDisk,
+		Visibility:           visibility,
 		Manipulations:        json.RawMessage("{}"),
 		CustomProperties:     json.RawMessage("{}"),
 		GeneratedConversions: json.RawMessage("{}"),
 		ResponsiveImages:     json.RawMessage("{}"),
-		Size:                 int64(len(fileContent)),
-		MimeType:             mimeType,
+		Metadata:             json.RawMessage("{}"),
+		Size:                 info.Size(),
 		CreatedAt:            time.Now(),
 		UpdatedAt:            time.Now(),
 	}
@@ -116,6 +110,13 @@ func (m *DefaultMediaLibrary) AddMediaFromDisk(
 		media.CustomProperties = customPropsBytes
 	}
 
+	// Extract metadata and apply the privacy policy, only JPEG content is read in full
+	contents, err := m.prepareContents(media, file, m.metadataPolicyFor(collection, opts))
+	if err != nil {
+		m.logger.Error("Failed to prepare file: %v", err)
+		return nil, err
+	}
+
 	m.logger.Debug("Detected mime type: %s for file size: %d bytes", media.MimeType, media.Size)
 
 	// Save to DB first to get the ID
@@ -129,31 +130,21 @@ func (m *DefaultMediaLibrary) AddMediaFromDisk(
 	path := m.pathGenerator.GetPath(media)
 	m.logger.Info("Saving media from disk path %s to storage path %s", filePath, path)
 
-	// Reset file pointer to beginning
-	file.Seek(0, 0)
-
 	// Save the file to disk
-	err = disk.Save(ctx, path, file,
-		storage.WithVisibility("public"))
+	err = disk.Save(ctx, path, contents,
+		storage.WithVisibility(media.GetVisibility()))
 	if err != nil {
 		m.logger.Error("Failed to store file: %v", err)
 		return nil, fmt.Errorf("failed to store file: %w", err)
 	}
 
-	if opts.AutoGenerateConversions && len(opts.PerformConversions) > 0 {
-		m.logger.Info("Performing %d conversions", len(opts.PerformConversions))
-		if err := m.PerformConversions(ctx, media, opts.PerformConversions...); err != nil {
+	if opts.AutoGenerateConversions && (len(opts.PerformConversions) > 0 || len(opts.GenerateResponsiveImages) > 0) {
+		m.logger.Info("Performing %d conversions and responsive images for %d conversions", len(opts.PerformConversions), len(opts.GenerateResponsiveImages))
+		if err := m.RunConversions(ctx, media, opts.PerformConversions, opts.GenerateResponsiveImages); err != nil {
 			m.logger.Warning("Failed to perform conversions: %v", err)
 		}
 	}
 
-	if opts.AutoGenerateConversions && len(opts.GenerateResponsiveImages) > 0 {
-		m.logger.Info("Generating responsive images for %d conversions", len(opts.GenerateResponsiveImages))
-		if err := m.GenerateResponsiveImages(ctx, media, opts.GenerateResponsiveImages...); err != nil {
-			m.logger.Warning("Failed to generate responsive images: %v", err)
-		}
-	}
-
 	return media, nil
 }
 
@@ -174,15 +165,15 @@ func (m *DefaultMediaLibrary) AddMediaFromDiskToDisk(
 		return nil, fmt.Errorf("failed to get source disk %s: %w", sourceDisk, err)
 	}
 
-	exists, err := sourceDiskStorage.Exists(ctx, sourcePath)
-	if err != nil {
-		m.logger.Error("Failed to check if file exists: %v", err)
-		return nil, fmt.Errorf("failed to check if file exists: %w", err)
-	}
-	if !exists {
+	info, err := sourceDiskStorage.Stat(ctx, sourcePath)
+	if errors.Is(err, storage.ErrNotFound) {
 		m.logger.Error("File %s does not exist on disk %s", sourcePath, sourceDisk)
 		return nil, fmt.Errorf("file %s does not exist on disk %s", sourcePath, sourceDisk)
 	}
+	if err != nil {
+		m.logger.Error("Failed to stat file: %v", err)
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
 
 	fileReader, err := sourceDiskStorage.Get(ctx, sourcePath)
 	if err != nil {
@@ -226,6 +217,12 @@ func (m *DefaultMediaLibrary) AddMediaFromDiskToDisk(
 		opts.Name = strings.TrimSuffix(baseName, filepath.Ext(baseName))
 	}
 
+	visibility, err := m.visibilityFor(collection, opts)
+	if err != nil {
+		m.logger.Error("Failed to resolve visibility: %v", err)
+		return nil, err
+	}
+
 	media := &models.Media{
 		ModelType:            opts.ModelType,
 		ModelID:              opts.ModelID,
@@ -235,10 +232,13 @@ func (m *DefaultMediaLibrary) AddMediaFromDiskToDisk(
 		FileName:             baseName,
 		Disk:                 targetDisk,
 		ConversionsDisk:      opts.ConversionsDisk,
+		Visibility:           visibility,
 		Manipulations:        json.RawMessage("{}"),
 		CustomProperties:     json.RawMessage("{}"),
 		GeneratedConversions: json.RawMessage("{}"),
 		ResponsiveImages:     json.RawMessage("{}"),
+		Metadata:             json.RawMessage("{}"),
+		Size:                 info.Size,
 		CreatedAt:            time.Now(),
 		UpdatedAt:            time.Now(),
 	}
@@ -252,25 +252,13 @@ func (m *DefaultMediaLibrary) AddMediaFromDiskToDisk(
 		media.CustomProperties = customPropsBytes
 	}
 
-	fileContent, err := ioutil.ReadAll(fileReader)
+	// Extract metadata and apply the privacy policy, only JPEG content is read in full
+	contents, err := m.prepareContents(media, fileReader, m.metadataPolicyFor(collection, opts))
 	if err != nil {
-		m.logger.Error("Failed to read file: %v", err)
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		m.logger.Error("Failed to prepare file: %v", err)
+		return nil, err
 	}
 
-	// Detect MIME type from content
-	contentReader := bytes.NewReader(fileContent)
-	mimeType, err := getMimeTypeFromContent(contentReader)
-	if err != nil {
-		m.logger.Warning("Failed to detect MIME type from content: %v, falling back to extension-based detection", err)
-		mimeType = getMimeTypeFromExtension(filepath.Ext(baseName))
-	}
-
-	// Reset content reader for potential future use
-	contentReader.Seek(0, 0)
-
-	media.Size = int64(len(fileContent))
-	media.MimeType = mimeType
 	m.logger.Debug("Detected mime type: %s for file size: %d bytes", media.MimeType, media.Size)
 
 	if err := m.repository.Save(ctx, media); err != nil {
@@ -282,26 +270,19 @@ func (m *DefaultMediaLibrary) AddMediaFromDiskToDisk(
 	path := m.pathGenerator.GetPath(media)
 	m.logger.Info("Saving media to target disk path: %s", path)
 
-	err = targetDiskStorage.Save(ctx, path, strings.NewReader(string(fileContent)),
-		storage.WithVisibility("public"))
+	err = targetDiskStorage.Save(ctx, path, contents,
+		storage.WithVisibility(media.GetVisibility()))
 	if err != nil {
 		m.logger.Error("Failed to store file: %v", err)
 		return nil, fmt.Errorf("failed to store file: %w", err)
 	}
 
-	if opts.AutoGenerateConversions && len(opts.PerformConversions) > 0 {
-		m.logger.Info("Performing %d conversions", len(opts.PerformConversions))
-		if err := m.PerformConversions(ctx, media, opts.PerformConversions...); err != nil {
+	if opts.AutoGenerateConversions && (len(opts.PerformConversions) > 0 || len(opts.GenerateResponsiveImages) > 0) {
+		m.logger.Info("Performing %d conversions and responsive images for %d conversions", len(opts.PerformConversions), len(opts.GenerateResponsiveImages))
+		if err := m.RunConversions(ctx, media, opts.PerformConversions, opts.GenerateResponsiveImages); err != nil {
 			m.logger.Warning("Failed to perform conversions: %v", err)
 		}
 	}
 
-	if opts.AutoGenerateConversions && len(opts.GenerateResponsiveImages) > 0 {
-		m.logger.Info("Generating responsive images for %d conversions", len(opts.GenerateResponsiveImages))
-		if err := m.GenerateResponsiveImages(ctx, media, opts.GenerateResponsiveImages...); err != nil {
-			m.logger.Warning("Failed to generate responsive images: %v", err)
-		}
-	}
-
 	return media, nil
 }
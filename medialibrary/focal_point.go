@@ -0,0 +1,109 @@
+package medialibrary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/vortechron/go-medialibrary/conversion"
+	"github.com/vortechron/go-medialibrary/models"
+)
+
+// getFocalPoint returns the focal point stored on the media record, or nil if none is set
+func getFocalPoint(media *models.Media) (*conversion.FocalPoint, error) {
+	if len(media.FocalPoint) == 0 || string(media.FocalPoint) == "null" || string(media.FocalPoint) == "{}" {
+		return nil, nil
+	}
+
+	var focalPoint conversion.FocalPoint
+	if err := json.Unmarshal(media.FocalPoint, &focalPoint); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal focal point: %w", err)
+	}
+
+	return &focalPoint, nil
+}
+
+// SetFocalPoint stores the focal point of the media in relative coordinates (0-1) and
+// regenerates the conversions and responsive images that were already generated
+func (m *DefaultMediaLibrary) SetFocalPoint(ctx context.Context, media *models.Media, x, y float64) error {
+	focalPoint := conversion.FocalPoint{X: x, Y: y}
+	if !focalPoint.Valid() {
+		return fmt.Errorf("invalid focal point %.3f,%.3f: coordinates must be between 0 and 1", x, y)
+	}
+
+	focalPointBytes, err := json.Marshal(focalPoint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal focal point: %w", err)
+	}
+
+	conversionNames := jsonObjectKeys(media.GeneratedConversions)
+	responsiveNames := jsonObjectKeys(media.ResponsiveImages)
+
+	media.FocalPoint = focalPointBytes
+	media.GeneratedConversions = json.RawMessage("{}")
+	media.ResponsiveImages = json.RawMessage("{}")
+	media.UpdatedAt = time.Now()
+
+	if err := m.repository.Save(ctx, media); err != nil {
+		m.logger.Error("Failed to save focal point for media ID %d: %v", media.ID, err)
+		return fmt.Errorf("failed to save media: %w", err)
+	}
+	m.logger.Info("Set focal point of media ID %d to %.3f,%.3f", media.ID, x, y)
+
+	if err := m.RunConversions(ctx, media, conversionNames, responsiveNames); err != nil {
+		return fmt.Errorf("failed to regenerate conversions: %w", err)
+	}
+
+	return nil
+}
+
+// DetectFocalPoint finds the most interesting region of the original image using
+// entropy-based smart cropping and stores its center as the focal point of the media
+func (m *DefaultMediaLibrary) DetectFocalPoint(ctx context.Context, media *models.Media) (*conversion.FocalPoint, error) {
+	m.logger.Debug("Detecting focal point for media ID %d", media.ID)
+
+	sourceDisk, err := m.diskManager.GetDisk(media.Disk)
+	if err != nil {
+		m.logger.Error("Failed to get source disk %s: %v", media.Disk, err)
+		return nil, fmt.Errorf("failed to get source disk %s: %w", media.Disk, err)
+	}
+
+	img, err := m.loadOriginalImage(ctx, sourceDisk, media)
+	if err != nil {
+		return nil, err
+	}
+
+	// A square window gives a focal point that works for both portrait and landscape crops
+	bounds := img.Bounds()
+	size := min(bounds.Dx(), bounds.Dy())
+
+	rect, err := conversion.SmartCrop(img, size, size)
+	if err != nil {
+		m.logger.Error("Failed to detect focal point: %v", err)
+		return nil, fmt.Errorf("failed to detect focal point: %w", err)
+	}
+
+	focalPoint := conversion.FocalPointFromRect(bounds, rect)
+	m.logger.Info("Detected focal point %.3f,%.3f for media ID %d", focalPoint.X, focalPoint.Y, media.ID)
+
+	if err := m.SetFocalPoint(ctx, media, focalPoint.X, focalPoint.Y); err != nil {
+		return nil, err
+	}
+
+	return &focalPoint, nil
+}
+
+// jsonObjectKeys returns the keys of a JSON object, or nil if the value is not an object
+func jsonObjectKeys(raw json.RawMessage) []string {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(object))
+	for k := range object {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,498 @@
+package medialibrary
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vortechron/go-medialibrary/conversion"
+	"github.com/vortechron/go-medialibrary/models"
+	"github.com/vortechron/go-medialibrary/storage"
+	"golang.org/x/sync/singleflight"
+)
+
+// ImageHandler serves conversions of media over HTTP and generates them on first request.
+//
+// GET /{uuid}/{conversion} serves a registered conversion. GET /{uuid}?w=300&h=200&fit=fill&fm=png&q=80&s=...
+// serves an ad-hoc transformation, which must be signed with SignImageURL. Mount the handler with
+// http.StripPrefix when it is not served from the root.
+type ImageHandler struct {
+	library           *DefaultMediaLibrary
+	signingKey        []byte
+	maxDimension      int
+	cacheControl      string
+	generationTimeout time.Duration
+	group             singleflight.Group
+	locks             mediaLocks
+}
+
+// ImageHandlerOption configures an ImageHandler
+type ImageHandlerOption func(*ImageHandler)
+
+// WithSigningKey sets the key used to verify ad-hoc transformation signatures.
+// Ad-hoc transformations are refused when no key is set.
+func WithSigningKey(key []byte) ImageHandlerOption {
+	return func(h *ImageHandler) {
+		h.signingKey = key
+	}
+}
+
+// WithMaxDimension limits the width and height of ad-hoc transformations
+func WithMaxDimension(pixels int) ImageHandlerOption {
+	return func(h *ImageHandler) {
+		h.maxDimension = pixels
+	}
+}
+
+// WithCacheControl sets the Cache-Control header sent with served images
+func WithCacheControl(value string) ImageHandlerOption {
+	return func(h *ImageHandler) {
+		h.cacheControl = value
+	}
+}
+
+// WithGenerationTimeout limits how long generating an image on request may take. Generation
+// is shared by all requests waiting for the image and continues when the client disconnects.
+func WithGenerationTimeout(timeout time.Duration) ImageHandlerOption {
+	return func(h *ImageHandler) {
+		h.generationTimeout = timeout
+	}
+}
+
+// NewImageHandler creates a new image handler for the media library
+func NewImageHandler(library *DefaultMediaLibrary, options ...ImageHandlerOption) *ImageHandler {
+	h := &ImageHandler{
+		library:           library,
+		maxDimension:      4000,
+		cacheControl:      "public, max-age=86400",
+		generationTimeout: time.Minute,
+	}
+
+	for _, option := range options {
+		option(h)
+	}
+
+	return h
+}
+
+// imageParams are the parameters of an ad-hoc transformation
+type imageParams struct {
+	width   int
+	height  int
+	fit     string
+	format  string
+	quality int
+}
+
+// errTooLarge is returned for ad-hoc transformations whose derived size exceeds the maximum dimension
+var errTooLarge = errors.New("image exceeds the maximum dimension")
+
+// cacheName returns the conversion name the transformation is cached under
+func (p imageParams) cacheName() string {
+	return fmt.Sprintf("adhoc-w%d-h%d-%s-q%d-%s", p.width, p.height, p.fit, p.quality, p.format)
+}
+
+// ServeHTTP implements http.Handler
+func (h *ImageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) > 2 || parts[0] == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	media, err := h.library.GetMediaByUUID(r.Context(), parts[0])
+	if err != nil {
+		h.library.logger.Error("Failed to find media %s: %v", parts[0], err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if media == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !h.library.supportsInputFormat(media.MimeType) {
+		http.Error(w, "media cannot be transformed", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if len(parts) == 2 {
+		// Registered conversions are not signed, private media is only served through signed URLs
+		if media.IsPrivate() {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		h.serveConversion(w, r, parts[0], media, parts[1])
+		return
+	}
+
+	h.serveAdHoc(w, r, parts[0], media)
+}
+
+// serveConversion serves a registered conversion, generating it if needed
+func (h *ImageHandler) serveConversion(w http.ResponseWriter, r *http.Request, mediaUUID string, media *models.Media, conversionName string) {
+	if _, registered := h.library.transformer.GetRegisteredConversions()[conversionName]; !registered {
+		http.NotFound(w, r)
+		return
+	}
+
+	result, generated := media.GetGeneratedConversion(conversionName)
+	if !generated {
+		key := mediaUUID + "/" + conversionName
+		_, err, _ := h.group.Do(key, func() (interface{}, error) {
+			ctx, cancel := h.generationContext(r)
+			defer cancel()
+			return nil, h.generateConversion(ctx, mediaUUID, conversionName)
+		})
+		if err != nil {
+			h.library.logger.Error("Failed to generate conversion %s for media ID %d: %v", conversionName, media.ID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		// The media record of the request that generated the conversion was updated, reload ours
+		if reloaded, err := h.library.GetMediaByUUID(r.Context(), mediaUUID); err == nil && reloaded != nil {
+			media = reloaded
+		}
+
+		result, generated = media.GetGeneratedConversion(conversionName)
+		if !generated {
+			http.Error(w, "conversion could not be generated", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	path := formatPath(h.library.pathGenerator.GetPathForConversion(media, conversionName), result.Format)
+	h.serveFile(w, r, media, path, result.MimeType)
+}
+
+// serveAdHoc serves a signed ad-hoc transformation, generating it if it is not cached yet
+func (h *ImageHandler) serveAdHoc(w http.ResponseWriter, r *http.Request, mediaUUID string, media *models.Media) {
+	query := r.URL.Query()
+
+	if len(h.signingKey) == 0 {
+		http.Error(w, "ad-hoc transformations are disabled", http.StatusForbidden)
+		return
+	}
+
+	if !h.validSignature(mediaUUID, query) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	// Private media is only served through URLs that expire, see SignImageURLWithExpiry
+	expires := query.Get("expires")
+	if expires == "" && media.IsPrivate() {
+		http.Error(w, "private media requires an expiring URL", http.StatusForbidden)
+		return
+	}
+	if expires != "" {
+		timestamp, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || time.Now().Unix() > timestamp {
+			http.Error(w, "URL expired", http.StatusForbidden)
+			return
+		}
+	}
+
+	params, err := h.parseParams(query, media)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	disk, err := h.library.diskManager.GetDisk(media.ConversionsDisk)
+	if err != nil {
+		h.library.logger.Error("Failed to get conversions disk %s: %v", media.ConversionsDisk, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	path := formatPath(h.library.pathGenerator.GetPathForConversion(media, params.cacheName()), params.format)
+
+	_, err, _ = h.group.Do(path, func() (interface{}, error) {
+		ctx, cancel := h.generationContext(r)
+		defer cancel()
+
+		exists, err := disk.Exists(ctx, path)
+		if err != nil || exists {
+			return nil, err
+		}
+		return nil, h.generateAdHoc(ctx, media, params, path)
+	})
+	if errors.Is(err, errTooLarge) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.library.logger.Error("Failed to generate %s for media ID %d: %v", params.cacheName(), media.ID, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	h.serveFile(w, r, media, path, conversion.FormatMimeType(params.format))
+}
+
+// generationContext returns the context images are generated with. It keeps the values of the
+// request but not its cancellation, as other requests may wait for the same image.
+func (h *ImageHandler) generationContext(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx := context.WithoutCancel(r.Context())
+	if h.generationTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, h.generationTimeout)
+}
+
+// generateConversion runs a registered conversion on a freshly loaded media record. Runs are
+// serialized per media item, as each one saves the whole record and would otherwise drop the
+// results of conversions generated at the same time.
+func (h *ImageHandler) generateConversion(ctx context.Context, mediaUUID string, conversionName string) error {
+	unlock := h.locks.lock(mediaUUID)
+	defer unlock()
+
+	media, err := h.library.GetMediaByUUID(ctx, mediaUUID)
+	if err != nil {
+		return err
+	}
+	if media == nil {
+		return fmt.Errorf("media %s not found", mediaUUID)
+	}
+
+	if _, generated := media.GetGeneratedConversion(conversionName); generated {
+		return nil
+	}
+
+	return h.library.RunConversions(ctx, media, []string{conversionName}, nil)
+}
+
+// generateAdHoc runs an ad-hoc transformation and caches it on the conversions disk
+func (h *ImageHandler) generateAdHoc(ctx context.Context, media *models.Media, params imageParams, path string) error {
+
+	sourceDisk, err := h.library.diskManager.GetDisk(media.Disk)
+	if err != nil {
+		return fmt.Errorf("failed to get source disk %s: %w", media.Disk, err)
+	}
+
+	conversionsDisk, err := h.library.diskManager.GetDisk(media.ConversionsDisk)
+	if err != nil {
+		return fmt.Errorf("failed to get conversions disk %s: %w", media.ConversionsDisk, err)
+	}
+
+	source, err := h.library.loadSource(ctx, sourceDisk, media)
+	if err != nil {
+		return err
+	}
+
+	// Derive a missing dimension from the aspect ratio of the original
+	width, height := params.width, params.height
+	bounds := source.image.Bounds()
+	if width == 0 {
+		width = max(bounds.Dx()*height/max(bounds.Dy(), 1), 1)
+	}
+	if height == 0 {
+		height = max(bounds.Dy()*width/max(bounds.Dx(), 1), 1)
+	}
+	if h.maxDimension > 0 && (width > h.maxDimension || height > h.maxDimension) {
+		return fmt.Errorf("%w: %dx%d, maximum %d", errTooLarge, width, height, h.maxDimension)
+	}
+
+	options := append(h.library.conversionOptionsForMedia(media),
+		conversion.WithFit(params.fit),
+		conversion.WithFormat(params.format),
+		conversion.WithQuality(params.quality),
+	)
+
+	transformed, err := h.library.transformer.ResizeImage(ctx, source.image, width, height, conversion.NewOptions(options...))
+	if err != nil {
+		return fmt.Errorf("failed to transform image: %w", err)
+	}
+
+	converted := &convertedImage{image: transformed, format: params.format, quality: params.quality}
+	if _, err := h.library.saveConvertedImage(ctx, conversionsDisk, path, converted, media); err != nil {
+		return fmt.Errorf("failed to store converted image: %w", err)
+	}
+
+	return nil
+}
+
+// parseParams reads and validates the ad-hoc transformation parameters
+func (h *ImageHandler) parseParams(query url.Values, media *models.Media) (imageParams, error) {
+	params := imageParams{
+		fit:     query.Get("fit"),
+		format:  query.Get("fm"),
+		quality: 90,
+	}
+
+	var err error
+	if params.width, err = parseDimension(query.Get("w"), h.maxDimension); err != nil {
+		return params, fmt.Errorf("invalid width: %w", err)
+	}
+	if params.height, err = parseDimension(query.Get("h"), h.maxDimension); err != nil {
+		return params, fmt.Errorf("invalid height: %w", err)
+	}
+	if params.width == 0 && params.height == 0 {
+		return params, fmt.Errorf("width or height is required")
+	}
+
+	switch params.fit {
+	case "":
+		params.fit = "contain"
+	case "contain", "max", "fill", "smart", "pad", "stretch":
+	default:
+		return params, fmt.Errorf("invalid fit %q", params.fit)
+	}
+
+	if params.format == "" {
+		params.format = outputFormat(media.FileName)
+	} else if params.format, err = conversion.OutputFormat(params.format); err != nil {
+		return params, fmt.Errorf("invalid format: %w", err)
+	}
+
+	if q := query.Get("q"); q != "" {
+		params.quality, err = strconv.Atoi(q)
+		if err != nil || params.quality < 1 || params.quality > 100 {
+			return params, fmt.Errorf("invalid quality %q", q)
+		}
+	}
+
+	return params, nil
+}
+
+// parseDimension parses an optional width or height
+func parseDimension(value string, maxDimension int) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	dimension, err := strconv.Atoi(value)
+	if err != nil || dimension < 0 {
+		return 0, fmt.Errorf("%q is not a positive number", value)
+	}
+	if maxDimension > 0 && dimension > maxDimension {
+		return 0, fmt.Errorf("%d exceeds the maximum of %d", dimension, maxDimension)
+	}
+
+	return dimension, nil
+}
+
+// validSignature checks the s parameter against the other parameters of the request
+func (h *ImageHandler) validSignature(mediaUUID string, query url.Values) bool {
+	signature, err := hex.DecodeString(query.Get("s"))
+	if err != nil || len(signature) == 0 {
+		return false
+	}
+
+	return hmac.Equal(signature, imageSignature(h.signingKey, mediaUUID, query))
+}
+
+// serveFile streams a stored image to the client
+func (h *ImageHandler) serveFile(w http.ResponseWriter, r *http.Request, media *models.Media, path string, mimeType string) {
+	disk, err := h.library.diskManager.GetDisk(media.ConversionsDisk)
+	if err != nil {
+		h.library.logger.Error("Failed to get conversions disk %s: %v", media.ConversionsDisk, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", mimeType)
+	if media.IsPrivate() {
+		w.Header().Set("Cache-Control", "private, no-store")
+	} else if h.cacheControl != "" {
+		w.Header().Set("Cache-Control", h.cacheControl)
+	}
+
+	storage.ServeObject(w, r, disk, path)
+}
+
+// SignImageURL returns the URL of a signed ad-hoc transformation served by an ImageHandler
+// mounted at baseURL, for example SignImageURL(key, "/images", uuid, url.Values{"w": {"300"}})
+func SignImageURL(key []byte, baseURL string, mediaUUID string, params url.Values) string {
+	signed := url.Values{}
+	for k, v := range params {
+		if k != "s" {
+			signed[k] = v
+		}
+	}
+	signed.Set("s", hex.EncodeToString(imageSignature(key, mediaUUID, signed)))
+
+	return strings.TrimSuffix(baseURL, "/") + "/" + mediaUUID + "?" + signed.Encode()
+}
+
+// SignImageURLWithExpiry is like SignImageURL but the URL stops working after the expiry.
+// Ad-hoc transformations of private media are only served through such URLs.
+func SignImageURLWithExpiry(key []byte, baseURL string, mediaUUID string, params url.Values, expiry time.Duration) string {
+	expiring := url.Values{}
+	for k, v := range params {
+		expiring[k] = v
+	}
+	expiring.Set("expires", strconv.FormatInt(time.Now().Add(expiry).Unix(), 10))
+
+	return SignImageURL(key, baseURL, mediaUUID, expiring)
+}
+
+// imageSignature computes the HMAC of the media UUID and the parameters, excluding the signature itself
+func imageSignature(key []byte, mediaUUID string, params url.Values) []byte {
+	unsigned := url.Values{}
+	for k, v := range params {
+		if k != "s" {
+			unsigned[k] = v
+		}
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(mediaUUID + "?" + unsigned.Encode()))
+	return mac.Sum(nil)
+}
+
+// mediaLocks hands out one mutex per media UUID, removing it when no request holds it
+type mediaLocks struct {
+	mu    sync.Mutex
+	locks map[string]*mediaLock
+}
+
+type mediaLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock locks the media UUID and returns the function unlocking it
+func (l *mediaLocks) lock(key string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*mediaLock)
+	}
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &mediaLock{}
+		l.locks[key] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.mu.Lock()
+
+	return func() {
+		lock.mu.Unlock()
+
+		l.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(l.locks, key)
+		}
+		l.mu.Unlock()
+	}
+}
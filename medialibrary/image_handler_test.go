@@ -0,0 +1,264 @@
+package medialibrary
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vortechron/go-medialibrary/conversion"
+	"github.com/vortechron/go-medialibrary/models"
+	"github.com/vortechron/go-medialibrary/storage"
+)
+
+// memoryRepository keeps media in a map, with the FindByUUID lookup the image handler needs
+type memoryRepository struct {
+	mu    sync.Mutex
+	media map[uint64]models.Media
+}
+
+func (r *memoryRepository) Save(ctx context.Context, media *models.Media) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if media.ID == 0 {
+		media.ID = uint64(len(r.media) + 1)
+	}
+	r.media[media.ID] = *media
+	return nil
+}
+
+func (r *memoryRepository) FindByID(ctx context.Context, id uint64) (*models.Media, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	media, ok := r.media[id]
+	if !ok {
+		return nil, nil
+	}
+	return &media, nil
+}
+
+func (r *memoryRepository) FindByUUID(ctx context.Context, uuid string) (*models.Media, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, media := range r.media {
+		if media.UUID != nil && media.UUID.String() == uuid {
+			return &media, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, media *models.Media) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.media, media.ID)
+	return nil
+}
+
+var testSigningKey = []byte("image-secret")
+
+type imageHandlerFixture struct {
+	handler     *ImageHandler
+	conversions *storage.MemoryStorage
+	public      string
+	private     string
+}
+
+// newImageHandlerFixture adds a public and a private 400x40 PNG and returns a handler serving them
+func newImageHandlerFixture(t *testing.T) *imageHandlerFixture {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "banner.png")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewNRGBA(image.Rect(0, 0, 400, 40))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	conversions := storage.NewMemoryStorage("")
+	diskManager := storage.NewDiskManager()
+	diskManager.AddDisk("originals", storage.NewMemoryStorage(""))
+	diskManager.AddDisk("conversions", conversions)
+
+	library := NewDefaultMediaLibrary(diskManager, conversion.NewImagingTransformer(),
+		&memoryRepository{media: make(map[uint64]models.Media)},
+		WithDefaultDisk("originals"),
+		WithConversionsDisk("conversions"),
+		WithLogLevel(LogLevelNone),
+	)
+
+	public, err := library.AddMediaFromDisk(context.Background(), file, "banners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := library.AddMediaFromDisk(context.Background(), file, "banners", WithVisibility(storage.VisibilityPrivate))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &imageHandlerFixture{
+		handler:     NewImageHandler(library, WithSigningKey(testSigningKey), WithMaxDimension(200)),
+		conversions: conversions,
+		public:      public.UUID.String(),
+		private:     private.UUID.String(),
+	}
+}
+
+func (f *imageHandlerFixture) get(t *testing.T, target string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	f.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func (f *imageHandlerFixture) objectCount(t *testing.T) int {
+	t.Helper()
+
+	page, err := f.conversions.List(context.Background(), "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(page.Objects)
+}
+
+func TestImageHandlerAdHoc(t *testing.T) {
+	f := newImageHandlerFixture(t)
+	params := url.Values{"w": {"100"}, "fit": {"fill"}, "h": {"20"}}
+
+	tests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{
+			name:   "signed",
+			target: SignImageURL(testSigningKey, "", f.public, params),
+			want:   http.StatusOK,
+		},
+		{
+			name:   "unsigned",
+			target: "/" + f.public + "?" + params.Encode(),
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "wrong key",
+			target: SignImageURL([]byte("other"), "", f.public, params),
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "extra parameter",
+			target: SignImageURL(testSigningKey, "", f.public, params) + "&q=10",
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "changed parameter",
+			target: strings.Replace(SignImageURL(testSigningKey, "", f.public, params), "w=100", "w=150", 1),
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "signature of another media item",
+			target: "/" + f.private + "?" + strings.SplitN(SignImageURLWithExpiry(testSigningKey, "", f.public, params, time.Hour), "?", 2)[1],
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "private without expiry",
+			target: SignImageURL(testSigningKey, "", f.private, params),
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "private with expiry",
+			target: SignImageURLWithExpiry(testSigningKey, "", f.private, params, time.Hour),
+			want:   http.StatusOK,
+		},
+		{
+			name:   "expired",
+			target: SignImageURLWithExpiry(testSigningKey, "", f.public, params, -time.Minute),
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "width above the maximum",
+			target: SignImageURL(testSigningKey, "", f.public, url.Values{"w": {"201"}}),
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "height above the maximum",
+			target: SignImageURL(testSigningKey, "", f.public, url.Values{"h": {"201"}}),
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "derived width above the maximum",
+			target: SignImageURL(testSigningKey, "", f.public, url.Values{"h": {"30"}}),
+			want:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := f.get(t, tt.target)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.want, strings.TrimSpace(rec.Body.String()))
+			}
+		})
+	}
+}
+
+func TestImageHandlerPrivateCacheControl(t *testing.T) {
+	f := newImageHandlerFixture(t)
+
+	rec := f.get(t, SignImageURLWithExpiry(testSigningKey, "", f.private, url.Values{"w": {"100"}}, time.Hour))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "private, no-store" {
+		t.Errorf("Cache-Control = %q, want private, no-store", cc)
+	}
+}
+
+func TestImageHandlerCacheIgnoresExpiry(t *testing.T) {
+	f := newImageHandlerFixture(t)
+	params := url.Values{"w": {"100"}}
+
+	before := f.objectCount(t)
+
+	for _, expiry := range []time.Duration{time.Hour, 2 * time.Hour} {
+		rec := f.get(t, SignImageURLWithExpiry(testSigningKey, "", f.private, params, expiry))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+	}
+
+	if got := f.objectCount(t) - before; got != 1 {
+		t.Fatalf("stored %d images for one transformation with two expiries, want 1", got)
+	}
+
+	// The expiry is not part of the parameters the cached image is named after
+	query := url.Values{"w": {"100"}, "expires": {strconv.FormatInt(time.Now().Unix(), 10)}}
+	withExpiry, err := f.handler.parseParams(query, &models.Media{FileName: "banner.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	without, err := f.handler.parseParams(url.Values{"w": {"100"}}, &models.Media{FileName: "banner.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withExpiry.cacheName() != without.cacheName() {
+		t.Errorf("cacheName() = %q with expires, %q without", withExpiry.cacheName(), without.cacheName())
+	}
+}
@@ -2,7 +2,10 @@ package medialibrary
 
 import (
 	"context"
+	"net/http"
 
+	"github.com/vortechron/go-medialibrary/conversion"
+	"github.com/vortechron/go-medialibrary/generator"
 	"github.com/vortechron/go-medialibrary/models"
 )
 
@@ -20,10 +23,32 @@ type MediaLibrary interface {
 
 	MoveMediaToDisk(ctx context.Context, media *models.Media, targetDisk string) (*models.Media, error)
 
+	DeleteMedia(ctx context.Context, media *models.Media) error
+
+	ClearConversions(ctx context.Context, media *models.Media) error
+
+	SetVisibility(ctx context.Context, media *models.Media, visibility string) error
+
 	PerformConversions(ctx context.Context, media *models.Media, conversionNames ...string) error
 
 	GenerateResponsiveImages(ctx context.Context, media *models.Media, conversionNames ...string) error
 
+	RunConversions(ctx context.Context, media *models.Media, conversionNames []string, responsiveNames []string) error
+
+	GetConversionStatus(media *models.Media) (*ConversionStatus, error)
+
+	GetConversionCounts(ctx context.Context, filter ConversionFilter) (ConversionCounts, error)
+
+	RetryFailedConversions(ctx context.Context, filter ConversionFilter) (int, error)
+
+	SupportedInputFormats() []string
+
+	RegisterImageGenerator(mimeType string, g generator.ImageGenerator)
+
+	SetFocalPoint(ctx context.Context, media *models.Media, x, y float64) error
+
+	DetectFocalPoint(ctx context.Context, media *models.Media) (*conversion.FocalPoint, error)
+
 	GetURLForMedia(media *models.Media) string
 
 	GetURLForMediaConversion(media *models.Media, conversionName string) string
@@ -36,6 +61,8 @@ type MediaLibrary interface {
 
 	GetMediaResponsiveImageUrl(media *models.Media, conversionName string, width int) string
 
+	ServeMedia(w http.ResponseWriter, r *http.Request, media *models.Media)
+
 	GetMediaRepository() MediaRepository
 
 	GetMediaForModel(ctx context.Context, modelType string, modelID uint64) ([]*models.Media, error)
@@ -10,6 +10,9 @@ import (
 	"github.com/vortechron/go-medialibrary/models"
 )
 
+// mimeSniffLength is the number of leading bytes used to detect a MIME type
+const mimeSniffLength = 3072
+
 // getMimeTypeFromContent detects the MIME type from file content
 func getMimeTypeFromContent(content io.Reader) (string, error) {
 	mime, err := mimetype.DetectReader(content)
@@ -31,6 +34,10 @@ func getMimeTypeFromExtension(ext string) string {
 		return "image/gif"
 	case ".webp":
 		return "image/webp"
+	case ".bmp":
+		return "image/bmp"
+	case ".tif", ".tiff":
+		return "image/tiff"
 	case ".svg":
 		return "image/svg+xml"
 	case ".mp4":
@@ -51,6 +58,19 @@ func (m *DefaultMediaLibrary) GetMediaRepository() MediaRepository {
 	return m.repository
 }
 
+// GetMediaByUUID returns the media item with the given UUID, or nil if it does not exist
+func (m *DefaultMediaLibrary) GetMediaByUUID(ctx context.Context, uuid string) (*models.Media, error) {
+	repo, ok := m.repository.(interface {
+		FindByUUID(ctx context.Context, uuid string) (*models.Media, error)
+	})
+
+	if !ok {
+		return nil, fmt.Errorf("repository does not support FindByUUID")
+	}
+
+	return repo.FindByUUID(ctx, uuid)
+}
+
 // GetMediaForModel returns all media items for a given model
 func (m *DefaultMediaLibrary) GetMediaForModel(ctx context.Context, modelType string, modelID uint64) ([]*models.Media, error) {
 	repo, ok := m.repository.(interface {
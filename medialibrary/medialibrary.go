@@ -2,6 +2,7 @@ package medialibrary
 
 import (
 	"github.com/vortechron/go-medialibrary/conversion"
+	"github.com/vortechron/go-medialibrary/generator"
 	"github.com/vortechron/go-medialibrary/storage"
 )
 
@@ -13,6 +14,12 @@ type DefaultMediaLibrary struct {
 	defaultOptions *Options
 	pathGenerator  PathGenerator
 	logger         Logger
+	generators     *generator.Registry
+}
+
+// diskManagerSetter is implemented by transformers that read files from disks, such as watermarks
+type diskManagerSetter interface {
+	SetDiskManager(diskManager *storage.DiskManager)
 }
 
 // NewDefaultMediaLibrary creates a new default media library instance
@@ -33,6 +40,11 @@ func NewDefaultMediaLibrary(
 		opt(opts)
 	}
 
+	// Transformers loading watermarks from disks use the disks of the library
+	if setter, ok := transformer.(diskManagerSetter); ok {
+		setter.SetDiskManager(diskManager)
+	}
+
 	return &DefaultMediaLibrary{
 		diskManager:    diskManager,
 		transformer:    transformer,
@@ -41,7 +53,8 @@ func NewDefaultMediaLibrary(
 		pathGenerator: &DefaultPathGenerator{
 			prefix: opts.PathGeneratorPrefix,
 		},
-		logger: NewDefaultLogger(opts.LogLevel),
+		logger:     NewDefaultLogger(opts.LogLevel),
+		generators: generator.NewDefaultRegistry(),
 	}
 }
 
@@ -0,0 +1,99 @@
+package medialibrary
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/vortechron/go-medialibrary/metadata"
+	"github.com/vortechron/go-medialibrary/models"
+)
+
+// metadataPolicyFor resolves the metadata policy for an add operation.
+// An explicit per-call policy wins over the collection policy, which wins over the library default.
+func (m *DefaultMediaLibrary) metadataPolicyFor(collection string, opts *Options) metadata.Policy {
+	if opts.MetadataPolicy != "" {
+		return opts.MetadataPolicy
+	}
+
+	if policy, ok := m.defaultOptions.CollectionMetadataPolicies[collection]; ok {
+		return policy
+	}
+
+	if m.defaultOptions.MetadataPolicy != "" {
+		return m.defaultOptions.MetadataPolicy
+	}
+
+	return metadata.PolicyKeep
+}
+
+// applyMetadataPolicy extracts EXIF/IPTC metadata from JPEG content into the media record
+// and strips the content according to the policy. It returns the content that should be stored
+// and whether it differs from the input.
+func (m *DefaultMediaLibrary) applyMetadataPolicy(media *models.Media, content []byte, policy metadata.Policy) ([]byte, bool, error) {
+	if media.MimeType != "image/jpeg" {
+		return content, false, nil
+	}
+
+	md, err := metadata.Extract(bytes.NewReader(content))
+	if err != nil {
+		m.logger.Warning("Failed to extract metadata for media %s: %v", media.FileName, err)
+	} else if !md.IsEmpty() {
+		metadataBytes, err := json.Marshal(md)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		media.Metadata = metadataBytes
+		m.logger.Debug("Extracted metadata for media %s: %s", media.FileName, string(metadataBytes))
+	}
+
+	if policy == "" || policy == metadata.PolicyKeep {
+		return content, false, nil
+	}
+
+	var stripped bytes.Buffer
+	if err := metadata.Strip(&stripped, bytes.NewReader(content), policy); err != nil {
+		return nil, false, fmt.Errorf("failed to strip metadata: %w", err)
+	}
+
+	m.logger.Debug("Stripped metadata (%s) from media %s: %d -> %d bytes", policy, media.FileName, len(content), stripped.Len())
+	return stripped.Bytes(), true, nil
+}
+
+// prepareContents detects the MIME type of the media from the head of the file and applies
+// the metadata policy. Only JPEG content is read in full, since the policy may rewrite it
+// and change media.Size; anything else is streamed from the returned reader.
+func (m *DefaultMediaLibrary) prepareContents(media *models.Media, file io.Reader, policy metadata.Policy) (io.Reader, error) {
+	head := make([]byte, mimeSniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	head = head[:n]
+
+	mimeType, err := getMimeTypeFromContent(bytes.NewReader(head))
+	if err != nil {
+		m.logger.Warning("Failed to detect MIME type from content: %v, falling back to extension-based detection", err)
+		mimeType = getMimeTypeFromExtension(filepath.Ext(media.FileName))
+	}
+	media.MimeType = mimeType
+
+	if media.MimeType != "image/jpeg" {
+		return io.MultiReader(bytes.NewReader(head), file), nil
+	}
+
+	rest, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	content, _, err := m.applyMetadataPolicy(media, append(head, rest...), policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process metadata: %w", err)
+	}
+	media.Size = int64(len(content))
+
+	return bytes.NewReader(content), nil
+}
@@ -1,21 +1,38 @@
 package medialibrary
 
+import (
+	"net/http"
+	"time"
+
+	"github.com/vortechron/go-medialibrary/metadata"
+)
+
 // Option is a function that configures Options
 type Option func(*Options)
 
 // Options holds the configuration for media operations
 type Options struct {
-	DefaultDisk              string
-	ConversionsDisk          string
-	AutoGenerateConversions  bool
-	PerformConversions       []string
-	GenerateResponsiveImages []string
-	CustomProperties         map[string]interface{}
-	ModelType                string
-	ModelID                  uint64
-	PathGeneratorPrefix      string
-	Name                     string
-	LogLevel                 LogLevel
+	DefaultDisk                string
+	ConversionsDisk            string
+	AutoGenerateConversions    bool
+	PerformConversions         []string
+	GenerateResponsiveImages   []string
+	CustomProperties           map[string]interface{}
+	ModelType                  string
+	ModelID                    uint64
+	PathGeneratorPrefix        string
+	Name                       string
+	LogLevel                   LogLevel
+	MetadataPolicy             metadata.Policy
+	CollectionMetadataPolicies map[string]metadata.Policy
+	GIFPosterConversions       []string
+	ConversionWorkers          int
+	ConversionMemoryBudget     int64
+	Visibility                 string
+	CollectionVisibilities     map[string]string
+	TemporaryURLExpiry         time.Duration
+	HTTPClient                 *http.Client
+	MaxDownloadSize            int64
 }
 
 // WithDefaultDisk sets the default disk for media storage
@@ -95,3 +112,82 @@ func WithLogLevel(level LogLevel) Option {
 		o.LogLevel = level
 	}
 }
+
+// WithMetadataPolicy sets how EXIF/IPTC metadata is handled in stored JPEG originals
+func WithMetadataPolicy(policy metadata.Policy) Option {
+	return func(o *Options) {
+		o.MetadataPolicy = policy
+	}
+}
+
+// WithCollectionMetadataPolicy sets the metadata policy for a specific collection
+func WithCollectionMetadataPolicy(collection string, policy metadata.Policy) Option {
+	return func(o *Options) {
+		if o.CollectionMetadataPolicies == nil {
+			o.CollectionMetadataPolicies = make(map[string]metadata.Policy)
+		}
+		o.CollectionMetadataPolicies[collection] = policy
+	}
+}
+
+// WithGIFPosterConversions specifies conversions that emit a static first frame for animated GIFs
+func WithGIFPosterConversions(conversions []string) Option {
+	return func(o *Options) {
+		o.GIFPosterConversions = conversions
+	}
+}
+
+// WithConversionWorkers sets how many images are generated concurrently in a conversion run
+func WithConversionWorkers(workers int) Option {
+	return func(o *Options) {
+		o.ConversionWorkers = workers
+	}
+}
+
+// WithConversionMemoryBudget limits the decoded pixels in flight across the conversion workers
+func WithConversionMemoryBudget(pixels int64) Option {
+	return func(o *Options) {
+		o.ConversionMemoryBudget = pixels
+	}
+}
+
+// WithVisibility sets whether media is public or private. Passed to NewDefaultMediaLibrary it
+// is the default for all media, passed when adding media it applies to that media item.
+// Values other than storage.VisibilityPublic and storage.VisibilityPrivate fail the add.
+func WithVisibility(visibility string) Option {
+	return func(o *Options) {
+		o.Visibility = visibility
+	}
+}
+
+// WithCollectionVisibility sets the visibility of media added to a specific collection
+func WithCollectionVisibility(collection string, visibility string) Option {
+	return func(o *Options) {
+		if o.CollectionVisibilities == nil {
+			o.CollectionVisibilities = make(map[string]string)
+		}
+		o.CollectionVisibilities[collection] = visibility
+	}
+}
+
+// WithTemporaryURLExpiry sets how long the temporary URLs returned for private media are valid
+func WithTemporaryURLExpiry(expiry time.Duration) Option {
+	return func(o *Options) {
+		o.TemporaryURLExpiry = expiry
+	}
+}
+
+// WithHTTPClient sets the client AddMediaFromURL downloads with. The default client gives
+// up after a minute, a custom client should set its own Timeout.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *Options) {
+		o.HTTPClient = client
+	}
+}
+
+// WithMaxDownloadSize sets the largest file in bytes AddMediaFromURL downloads, 100 MiB by default
+func WithMaxDownloadSize(size int64) Option {
+	return func(o *Options) {
+		o.MaxDownloadSize = size
+	}
+}
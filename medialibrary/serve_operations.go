@@ -0,0 +1,29 @@
+package medialibrary
+
+import (
+	"net/http"
+
+	"github.com/vortechron/go-medialibrary/models"
+	"github.com/vortechron/go-medialibrary/storage"
+)
+
+// ServeMedia writes the original file of a media item to the response. Range requests are
+// answered with 206 Partial Content and only read the requested bytes from the disk, so audio
+// and video can be streamed and seeked.
+func (m *DefaultMediaLibrary) ServeMedia(w http.ResponseWriter, r *http.Request, media *models.Media) {
+	disk, err := m.diskManager.GetDisk(media.Disk)
+	if err != nil {
+		m.logger.Error("Failed to get disk %s: %v", media.Disk, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if media.MimeType != "" {
+		w.Header().Set("Content-Type", media.MimeType)
+	}
+	if media.IsPrivate() {
+		w.Header().Set("Cache-Control", "private, no-store")
+	}
+
+	storage.ServeObject(w, r, disk, m.pathGenerator.GetPath(media))
+}
@@ -1,8 +1,6 @@
 package medialibrary
 
 import (
-	"encoding/json"
-
 	"github.com/vortechron/go-medialibrary/models"
 )
 
@@ -21,7 +19,7 @@ func (m *DefaultMediaLibrary) GetURLForMedia(media *models.Media) string {
 	}
 
 	path := m.pathGenerator.GetPath(media)
-	url := disk.URL(path)
+	url := m.fileURL(disk, media, path)
 	m.logger.Debug("Generated URL for media ID %d: %s", media.ID, url)
 	return url
 }
@@ -33,13 +31,14 @@ func (m *DefaultMediaLibrary) GetURLForMediaConversion(media *models.Media, conv
 		return ""
 	}
 
-	generatedConversions := make(map[string]bool)
-	if err := json.Unmarshal(media.GeneratedConversions, &generatedConversions); err != nil {
-		m.logger.Error("Error unmarshalling generated conversions: %v", err)
+	generatedConversions, err := media.GetGeneratedConversions()
+	if err != nil {
+		m.logger.Error("Error reading generated conversions: %v", err)
 		return ""
 	}
 
-	if !generatedConversions[conversionName] {
+	result, ok := generatedConversions[conversionName]
+	if !ok || !result.Done() {
 		m.logger.Debug("Conversion %s not found for media ID %d", conversionName, media.ID)
 		return ""
 	}
@@ -50,8 +49,8 @@ func (m *DefaultMediaLibrary) GetURLForMediaConversion(media *models.Media, conv
 		return ""
 	}
 
-	path := m.pathGenerator.GetPathForConversion(media, conversionName)
-	url := disk.URL(path)
+	path := formatPath(m.pathGenerator.GetPathForConversion(media, conversionName), result.Format)
+	url := m.fileURL(disk, media, path)
 	m.logger.Debug("Generated URL for media ID %d conversion %s: %s", media.ID, conversionName, url)
 	return url
 }
@@ -63,9 +62,9 @@ func (m *DefaultMediaLibrary) GetURLForResponsiveImage(media *models.Media, conv
 		return ""
 	}
 
-	responsiveImages := make(map[string]map[string][]int)
-	if err := json.Unmarshal(media.ResponsiveImages, &responsiveImages); err != nil {
-		m.logger.Error("Error unmarshalling responsive images: %v", err)
+	responsiveImages, err := media.GetResponsiveImages()
+	if err != nil {
+		m.logger.Error("Error reading responsive images: %v", err)
 		return ""
 	}
 
@@ -74,21 +73,8 @@ func (m *DefaultMediaLibrary) GetURLForResponsiveImage(media *models.Media, conv
 		return ""
 	}
 
-	if _, ok := responsiveImages[conversionName]["widths"]; !ok {
-		m.logger.Debug("No widths found for conversion %s media ID %d", conversionName, media.ID)
-		return ""
-	}
-
-	widths := responsiveImages[conversionName]["widths"]
-	found := false
-	for _, w := range widths {
-		if w == width {
-			found = true
-			break
-		}
-	}
-
-	if !found {
+	result, ok := responsiveImages[conversionName][width]
+	if !ok || !result.Done() {
 		m.logger.Debug("Width %d not found for conversion %s media ID %d", width, conversionName, media.ID)
 		return ""
 	}
@@ -99,8 +85,8 @@ func (m *DefaultMediaLibrary) GetURLForResponsiveImage(media *models.Media, conv
 		return ""
 	}
 
-	path := m.pathGenerator.GetPathForResponsiveImage(media, conversionName, width)
-	url := disk.URL(path)
+	path := formatPath(m.pathGenerator.GetPathForResponsiveImage(media, conversionName, width), result.Format)
+	url := m.fileURL(disk, media, path)
 	m.logger.Debug("Generated URL for media ID %d responsive image %s width %d: %s", media.ID, conversionName, width, url)
 	return url
 }
@@ -120,7 +106,7 @@ func (m *DefaultMediaLibrary) GetMediaUrl(media *models.Media) string {
 	}
 
 	path := m.pathGenerator.GetPath(media)
-	url := disk.URL(path)
+	url := m.fileURL(disk, media, path)
 	m.logger.Debug("Generated URL for media ID %d: %s", media.ID, url)
 	return url
 }
@@ -133,13 +119,14 @@ func (m *DefaultMediaLibrary) GetMediaConversionUrl(media *models.Media, convers
 		return ""
 	}
 
-	generatedConversions := make(map[string]bool)
-	if err := json.Unmarshal(media.GeneratedConversions, &generatedConversions); err != nil {
-		m.logger.Error("Error unmarshalling generated conversions: %v", err)
+	generatedConversions, err := media.GetGeneratedConversions()
+	if err != nil {
+		m.logger.Error("Error reading generated conversions: %v", err)
 		return ""
 	}
 
-	if !generatedConversions[conversionName] {
+	result, ok := generatedConversions[conversionName]
+	if !ok || !result.Done() {
 		m.logger.Debug("Conversion %s not found for media ID %d", conversionName, media.ID)
 		return ""
 	}
@@ -150,8 +137,8 @@ func (m *DefaultMediaLibrary) GetMediaConversionUrl(media *models.Media, convers
 		return ""
 	}
 
-	path := m.pathGenerator.GetPathForConversion(media, conversionName)
-	url := disk.URL(path)
+	path := formatPath(m.pathGenerator.GetPathForConversion(media, conversionName), result.Format)
+	url := m.fileURL(disk, media, path)
 	m.logger.Debug("Generated URL for media ID %d conversion %s: %s", media.ID, conversionName, url)
 	return url
 }
@@ -164,9 +151,9 @@ func (m *DefaultMediaLibrary) GetMediaResponsiveImageUrl(media *models.Media, co
 		return ""
 	}
 
-	responsiveImages := make(map[string]map[string][]int)
-	if err := json.Unmarshal(media.ResponsiveImages, &responsiveImages); err != nil {
-		m.logger.Error("Error unmarshalling responsive images: %v", err)
+	responsiveImages, err := media.GetResponsiveImages()
+	if err != nil {
+		m.logger.Error("Error reading responsive images: %v", err)
 		return ""
 	}
 
@@ -175,21 +162,8 @@ func (m *DefaultMediaLibrary) GetMediaResponsiveImageUrl(media *models.Media, co
 		return ""
 	}
 
-	if _, ok := responsiveImages[conversionName]["widths"]; !ok {
-		m.logger.Debug("No widths found for conversion %s media ID %d", conversionName, media.ID)
-		return ""
-	}
-
-	widths := responsiveImages[conversionName]["widths"]
-	found := false
-	for _, w := range widths {
-		if w == width {
-			found = true
-			break
-		}
-	}
-
-	if !found {
+	result, ok := responsiveImages[conversionName][width]
+	if !ok || !result.Done() {
 		m.logger.Debug("Width %d not found for conversion %s media ID %d", width, conversionName, media.ID)
 		return ""
 	}
@@ -200,8 +174,8 @@ func (m *DefaultMediaLibrary) GetMediaResponsiveImageUrl(media *models.Media, co
 		return ""
 	}
 
-	path := m.pathGenerator.GetPathForResponsiveImage(media, conversionName, width)
-	url := disk.URL(path)
+	path := formatPath(m.pathGenerator.GetPathForResponsiveImage(media, conversionName, width), result.Format)
+	url := m.fileURL(disk, media, path)
 	m.logger.Debug("Generated URL for media ID %d responsive image %s width %d: %s", media.ID, conversionName, width, url)
 	return url
 }
@@ -0,0 +1,108 @@
+package medialibrary
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vortechron/go-medialibrary/models"
+	"github.com/vortechron/go-medialibrary/storage"
+)
+
+// defaultTemporaryURLExpiry is the lifetime of temporary URLs for private media
+const defaultTemporaryURLExpiry = time.Hour
+
+// visibilityFor returns the visibility for new media: the per-media option, then the
+// collection setting, then the library default, public when none is set. A value other
+// than public or private is an error rather than silently stored.
+func (m *DefaultMediaLibrary) visibilityFor(collection string, opts *Options) (string, error) {
+	visibility := storage.VisibilityPublic
+
+	if opts.Visibility != "" {
+		visibility = opts.Visibility
+	} else if collectionVisibility, ok := m.defaultOptions.CollectionVisibilities[collection]; ok {
+		visibility = collectionVisibility
+	} else if m.defaultOptions.Visibility != "" {
+		visibility = m.defaultOptions.Visibility
+	}
+
+	if err := validateVisibility(visibility); err != nil {
+		return "", err
+	}
+
+	return visibility, nil
+}
+
+func validateVisibility(visibility string) error {
+	if visibility != storage.VisibilityPublic && visibility != storage.VisibilityPrivate {
+		return fmt.Errorf("invalid visibility %q", visibility)
+	}
+	return nil
+}
+
+// SetVisibility changes the visibility of a media item, its conversions and responsive
+// images on their disks and stores it on the media
+func (m *DefaultMediaLibrary) SetVisibility(ctx context.Context, media *models.Media, visibility string) error {
+	if err := validateVisibility(visibility); err != nil {
+		return err
+	}
+
+	m.logger.Debug("Setting visibility of media ID %d to %s", media.ID, visibility)
+
+	for _, diskName := range mediaDisks(media) {
+		disk, err := m.diskManager.GetDisk(diskName)
+		if err != nil {
+			m.logger.Error("Failed to get disk %s: %v", diskName, err)
+			return fmt.Errorf("failed to get disk %s: %w", diskName, err)
+		}
+
+		setter, ok := disk.(storage.VisibilitySetter)
+		if !ok {
+			return fmt.Errorf("disk %s does not support changing visibility", diskName)
+		}
+
+		objects, err := listObjects(ctx, disk, m.mediaDirectory(media), true)
+		if err != nil {
+			m.logger.Error("Failed to list files of media ID %d on disk %s: %v", media.ID, diskName, err)
+			return fmt.Errorf("failed to list media files: %w", err)
+		}
+
+		for _, object := range objects {
+			if err := setter.SetVisibility(ctx, object.Path, visibility); err != nil {
+				m.logger.Error("Failed to set visibility of %s: %v", object.Path, err)
+				return fmt.Errorf("failed to set visibility of %s: %w", object.Path, err)
+			}
+		}
+	}
+
+	media.Visibility = visibility
+	media.UpdatedAt = time.Now()
+
+	if err := m.repository.Save(ctx, media); err != nil {
+		m.logger.Error("Failed to save media visibility: %v", err)
+		return fmt.Errorf("failed to save media: %w", err)
+	}
+
+	m.logger.Info("Set visibility of media ID %d to %s", media.ID, visibility)
+	return nil
+}
+
+// fileURL returns the URL of a file of the media, a temporary URL when the media is private
+func (m *DefaultMediaLibrary) fileURL(disk storage.Storage, media *models.Media, path string) string {
+	if !media.IsPrivate() {
+		return disk.URL(path)
+	}
+
+	expiry := m.defaultOptions.TemporaryURLExpiry
+	if expiry <= 0 {
+		expiry = defaultTemporaryURLExpiry
+	}
+
+	url, err := disk.TemporaryURL(context.Background(), path, int64(expiry/time.Second))
+	if err != nil {
+		m.logger.Error("Error creating temporary URL for %s: %v", path, err)
+		return ""
+	}
+
+	return url
+}
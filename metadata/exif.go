@@ -0,0 +1,417 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	tagMake             = 0x010f
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagSoftware         = 0x0131
+	tagDateTime         = 0x0132
+	tagArtist           = 0x013b
+	tagCopyright        = 0x8298
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagExposureTime     = 0x829a
+	tagFNumber          = 0x829d
+	tagISO              = 0x8827
+	tagDateTimeOriginal = 0x9003
+	tagOffsetTimeOrig   = 0x9011
+	tagFocalLength      = 0x920a
+	tagPixelXDimension  = 0xa002
+	tagPixelYDimension  = 0xa003
+	tagLensModel        = 0xa434
+
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+	tagGPSAltitudeRef  = 0x0005
+	tagGPSAltitude     = 0x0006
+)
+
+const (
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeUndefined = 7
+	typeSLong     = 9
+	typeSRational = 10
+)
+
+var typeSizes = map[uint16]int{
+	typeByte:      1,
+	typeASCII:     1,
+	typeShort:     2,
+	typeLong:      4,
+	typeRational:  8,
+	typeUndefined: 1,
+	typeSLong:     4,
+	typeSRational: 8,
+}
+
+var errInvalidTIFF = errors.New("invalid TIFF structure")
+
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+func newTIFF(data []byte) (*tiff, uint32, error) {
+	if len(data) < 8 {
+		return nil, 0, errInvalidTIFF
+	}
+
+	t := &tiff{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, 0, errInvalidTIFF
+	}
+
+	if t.order.Uint16(data[2:4]) != 42 {
+		return nil, 0, errInvalidTIFF
+	}
+
+	return t, t.order.Uint32(data[4:8]), nil
+}
+
+func (t *tiff) readIFD(offset uint32) ([]ifdEntry, error) {
+	start := int(offset)
+	if start < 8 || start+2 > len(t.data) {
+		return nil, errInvalidTIFF
+	}
+
+	count := int(t.order.Uint16(t.data[start:]))
+	if start+2+count*12 > len(t.data) {
+		return nil, errInvalidTIFF
+	}
+
+	entries := make([]ifdEntry, 0, count)
+	for i := 0; i < count; i++ {
+		pos := start + 2 + i*12
+		entry := ifdEntry{
+			tag:   t.order.Uint16(t.data[pos:]),
+			typ:   t.order.Uint16(t.data[pos+2:]),
+			count: t.order.Uint32(t.data[pos+4:]),
+		}
+
+		size, ok := typeSizes[entry.typ]
+		if !ok {
+			continue
+		}
+
+		total := size * int(entry.count)
+		if total < 0 || entry.count > uint32(len(t.data)) {
+			continue
+		}
+
+		if total <= 4 {
+			entry.value = t.data[pos+8 : pos+8+total]
+		} else {
+			valueOffset := int(t.order.Uint32(t.data[pos+8:]))
+			if valueOffset < 0 || valueOffset+total > len(t.data) {
+				continue
+			}
+			entry.value = t.data[valueOffset : valueOffset+total]
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (t *tiff) str(e ifdEntry) string {
+	return strings.TrimSpace(strings.TrimRight(string(e.value), "\x00"))
+}
+
+func (t *tiff) uint(e ifdEntry) uint32 {
+	switch e.typ {
+	case typeByte, typeUndefined:
+		if len(e.value) > 0 {
+			return uint32(e.value[0])
+		}
+	case typeShort:
+		if len(e.value) >= 2 {
+			return uint32(t.order.Uint16(e.value))
+		}
+	case typeLong, typeSLong:
+		if len(e.value) >= 4 {
+			return t.order.Uint32(e.value)
+		}
+	}
+	return 0
+}
+
+func (t *tiff) rational(e ifdEntry, index int) (num, den int64, ok bool) {
+	if e.typ != typeRational && e.typ != typeSRational {
+		return 0, 0, false
+	}
+	pos := index * 8
+	if pos+8 > len(e.value) {
+		return 0, 0, false
+	}
+
+	if e.typ == typeSRational {
+		return int64(int32(t.order.Uint32(e.value[pos:]))), int64(int32(t.order.Uint32(e.value[pos+4:]))), true
+	}
+	return int64(t.order.Uint32(e.value[pos:])), int64(t.order.Uint32(e.value[pos+4:])), true
+}
+
+func (t *tiff) float(e ifdEntry, index int) float64 {
+	num, den, ok := t.rational(e, index)
+	if !ok || den == 0 {
+		return 0
+	}
+	return float64(num) / float64(den)
+}
+
+func parseExif(data []byte, md *Metadata) error {
+	t, ifd0, err := newTIFF(data)
+	if err != nil {
+		return err
+	}
+
+	entries, err := t.readIFD(ifd0)
+	if err != nil {
+		return err
+	}
+
+	camera := &Camera{}
+	var dateTime, dateTimeOriginal, offsetTime string
+
+	for _, e := range entries {
+		switch e.tag {
+		case tagMake:
+			camera.Make = t.str(e)
+		case tagModel:
+			camera.Model = t.str(e)
+		case tagOrientation:
+			md.Orientation = int(t.uint(e))
+		case tagSoftware:
+			md.Software = t.str(e)
+		case tagArtist:
+			md.Artist = t.str(e)
+		case tagCopyright:
+			md.Copyright = t.str(e)
+		case tagDateTime:
+			dateTime = t.str(e)
+		case tagExifIFD:
+			exifEntries, err := t.readIFD(t.uint(e))
+			if err != nil {
+				continue
+			}
+			for _, x := range exifEntries {
+				switch x.tag {
+				case tagDateTimeOriginal:
+					dateTimeOriginal = t.str(x)
+				case tagOffsetTimeOrig:
+					offsetTime = t.str(x)
+				case tagLensModel:
+					camera.LensModel = t.str(x)
+				case tagExposureTime:
+					if num, den, ok := t.rational(x, 0); ok && den != 0 {
+						camera.ExposureTime = formatExposure(num, den)
+					}
+				case tagFNumber:
+					camera.FNumber = t.float(x, 0)
+				case tagISO:
+					camera.ISO = int(t.uint(x))
+				case tagFocalLength:
+					camera.FocalLength = t.float(x, 0)
+				case tagPixelXDimension:
+					md.Width = int(t.uint(x))
+				case tagPixelYDimension:
+					md.Height = int(t.uint(x))
+				}
+			}
+		case tagGPSIFD:
+			gpsEntries, err := t.readIFD(t.uint(e))
+			if err != nil {
+				continue
+			}
+			md.GPS = parseGPS(t, gpsEntries)
+		}
+	}
+
+	if *camera != (Camera{}) {
+		md.Camera = camera
+	}
+
+	if dateTimeOriginal == "" {
+		dateTimeOriginal = dateTime
+	}
+	if capturedAt, ok := parseExifTime(dateTimeOriginal, offsetTime); ok {
+		md.CapturedAt = &capturedAt
+	}
+
+	return nil
+}
+
+func parseGPS(t *tiff, entries []ifdEntry) *GPS {
+	var latRef, lonRef string
+	var lat, lon, alt *ifdEntry
+	altRef := uint32(0)
+
+	for i := range entries {
+		e := entries[i]
+		switch e.tag {
+		case tagGPSLatitudeRef:
+			latRef = t.str(e)
+		case tagGPSLatitude:
+			lat = &entries[i]
+		case tagGPSLongitudeRef:
+			lonRef = t.str(e)
+		case tagGPSLongitude:
+			lon = &entries[i]
+		case tagGPSAltitudeRef:
+			altRef = t.uint(e)
+		case tagGPSAltitude:
+			alt = &entries[i]
+		}
+	}
+
+	if lat == nil || lon == nil {
+		return nil
+	}
+
+	gps := &GPS{
+		Latitude:  degrees(t, *lat),
+		Longitude: degrees(t, *lon),
+	}
+
+	if latRef == "S" {
+		gps.Latitude = -gps.Latitude
+	}
+	if lonRef == "W" {
+		gps.Longitude = -gps.Longitude
+	}
+
+	if alt != nil {
+		altitude := t.float(*alt, 0)
+		if altRef == 1 {
+			altitude = -altitude
+		}
+		gps.Altitude = &altitude
+	}
+
+	return gps
+}
+
+func degrees(t *tiff, e ifdEntry) float64 {
+	d := t.float(e, 0)
+	m := t.float(e, 1)
+	s := t.float(e, 2)
+	return math.Round((d+m/60+s/3600)*1e7) / 1e7
+}
+
+func formatExposure(num, den int64) string {
+	if num == 0 {
+		return "0"
+	}
+	if num < den {
+		return fmt.Sprintf("1/%d", int64(math.Round(float64(den)/float64(num))))
+	}
+	return fmt.Sprintf("%g", float64(num)/float64(den))
+}
+
+func parseExifTime(value, offset string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	if offset != "" {
+		if t, err := time.Parse("2006:01:02 15:04:05-07:00", value+offset); err == nil {
+			return t, true
+		}
+	}
+
+	t, err := time.Parse("2006:01:02 15:04:05", value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// stripGPS removes every GPS IFD pointer from IFD0 and zeroes the GPS IFDs in
+// place so the segment keeps its size and all other offsets stay valid.
+func stripGPS(data []byte) error {
+	t, ifd0, err := newTIFF(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := t.readIFD(ifd0); err != nil {
+		return err
+	}
+
+	start := int(ifd0)
+	count := int(t.order.Uint16(data[start:]))
+
+	for i := 0; i < count; {
+		pos := start + 2 + i*12
+		if t.order.Uint16(data[pos:]) != tagGPSIFD {
+			i++
+			continue
+		}
+
+		gpsOffset := t.order.Uint32(data[pos+8:])
+		if gpsEntries, err := t.readIFD(gpsOffset); err == nil {
+			for _, e := range gpsEntries {
+				zero(e.value)
+			}
+			// readIFD skips entries of unknown types, zero every entry the IFD declares
+			gpsStart := int(gpsOffset)
+			gpsCount := int(t.order.Uint16(data[gpsStart:]))
+			zero(data[gpsStart : gpsStart+2+gpsCount*12])
+		}
+
+		// Shift the remaining entries and the next IFD offset down by one
+		end := start + 2 + count*12 + 4
+		if end > len(data) {
+			end = start + 2 + count*12
+		}
+		copy(data[pos:], data[pos+12:end])
+		zero(data[end-12 : end])
+		count--
+		t.order.PutUint16(data[start:], uint16(count))
+	}
+
+	return nil
+}
+
+func orientationExif(orientation int) []byte {
+	data := append([]byte(nil), exifHeader...)
+	data = append(data, 'M', 'M', 0, 42, 0, 0, 0, 8)
+	data = append(data, 0, 1)
+	data = append(data, byte(tagOrientation>>8), byte(tagOrientation&0xff), 0, typeShort, 0, 0, 0, 1)
+	data = append(data, byte(orientation>>8), byte(orientation), 0, 0)
+	data = append(data, 0, 0, 0, 0)
+	return data
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
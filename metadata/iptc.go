@@ -0,0 +1,105 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+)
+
+const resourceIPTC = 0x0404
+
+// parseIPTC reads the IPTC-NAA record from the image resource blocks of a
+// Photoshop APP13 segment.
+func parseIPTC(data []byte) *IPTC {
+	for len(data) >= 12 {
+		if !bytes.HasPrefix(data, []byte("8BIM")) {
+			return nil
+		}
+
+		id := binary.BigEndian.Uint16(data[4:6])
+
+		// Pascal string name, padded to an even length
+		nameLen := int(data[6]) + 1
+		if nameLen%2 != 0 {
+			nameLen++
+		}
+
+		pos := 6 + nameLen
+		if pos+4 > len(data) {
+			return nil
+		}
+
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		pos += 4
+		if size < 0 || pos+size > len(data) {
+			return nil
+		}
+
+		if id == resourceIPTC {
+			return parseIIM(data[pos : pos+size])
+		}
+
+		if size%2 != 0 {
+			size++
+		}
+		if pos+size > len(data) {
+			return nil
+		}
+		data = data[pos+size:]
+	}
+
+	return nil
+}
+
+func parseIIM(data []byte) *IPTC {
+	iptc := &IPTC{}
+	found := false
+
+	for len(data) >= 5 && data[0] == 0x1c {
+		record := data[1]
+		dataset := data[2]
+		length := int(binary.BigEndian.Uint16(data[3:5]))
+
+		// Extended datasets are not used by any of the fields we read
+		if length&0x8000 != 0 {
+			return nil
+		}
+
+		if 5+length > len(data) {
+			break
+		}
+
+		value := strings.TrimSpace(string(data[5 : 5+length]))
+		data = data[5+length:]
+
+		if record != 2 {
+			continue
+		}
+
+		found = true
+		switch dataset {
+		case 5:
+			iptc.Title = value
+		case 25:
+			iptc.Keywords = append(iptc.Keywords, value)
+		case 80:
+			iptc.Creator = value
+		case 90:
+			iptc.City = value
+		case 101:
+			iptc.Country = value
+		case 105:
+			iptc.Headline = value
+		case 116:
+			iptc.Copyright = value
+		case 120:
+			iptc.Caption = value
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	return iptc
+}
@@ -0,0 +1,112 @@
+package metadata
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	markerSOI   = 0xd8
+	markerEOI   = 0xd9
+	markerSOS   = 0xda
+	markerAPP0  = 0xe0
+	markerAPP1  = 0xe1
+	markerAPP12 = 0xec
+	markerAPP13 = 0xed
+	markerCOM   = 0xfe
+)
+
+var (
+	exifHeader      = []byte("Exif\x00\x00")
+	xmpHeader       = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	photoshopHeader = []byte("Photoshop 3.0\x00")
+)
+
+// readSegments walks the marker segments of a JPEG stream up to and including
+// the start of scan segment. The reader is left positioned at the first byte of
+// the compressed image data.
+func readSegments(r *bufio.Reader, fn func(marker byte, data []byte) error) error {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil {
+		return ErrNotJPEG
+	}
+	if soi[0] != 0xff || soi[1] != markerSOI {
+		return ErrNotJPEG
+	}
+
+	for {
+		marker, err := readMarker(r)
+		if err != nil {
+			return err
+		}
+
+		if marker == markerEOI {
+			return nil
+		}
+
+		// Markers without a payload
+		if marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) {
+			continue
+		}
+
+		var lengthBytes [2]byte
+		if _, err := io.ReadFull(r, lengthBytes[:]); err != nil {
+			return fmt.Errorf("failed to read segment length: %w", err)
+		}
+
+		length := int(binary.BigEndian.Uint16(lengthBytes[:]))
+		if length < 2 {
+			return fmt.Errorf("invalid segment length %d", length)
+		}
+
+		data := make([]byte, length-2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return fmt.Errorf("failed to read segment: %w", err)
+		}
+
+		if err := fn(marker, data); err != nil {
+			return err
+		}
+
+		if marker == markerSOS {
+			return nil
+		}
+	}
+}
+
+func readMarker(r *bufio.Reader) (byte, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read marker: %w", err)
+	}
+	if b != 0xff {
+		return 0, fmt.Errorf("invalid marker prefix 0x%02x", b)
+	}
+
+	// Skip fill bytes
+	for b == 0xff {
+		b, err = r.ReadByte()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read marker: %w", err)
+		}
+	}
+
+	return b, nil
+}
+
+func writeSegment(w io.Writer, marker byte, data []byte) error {
+	if len(data)+2 > 0xffff {
+		return fmt.Errorf("segment too large: %d bytes", len(data))
+	}
+
+	header := []byte{0xff, marker, 0, 0}
+	binary.BigEndian.PutUint16(header[2:], uint16(len(data)+2))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
@@ -0,0 +1,176 @@
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+type Policy string
+
+const (
+	PolicyKeep     Policy = "keep"
+	PolicyStripGPS Policy = "strip_gps"
+	PolicyStripAll Policy = "strip_all"
+)
+
+var ErrNotJPEG = errors.New("not a JPEG image")
+
+type Metadata struct {
+	Camera      *Camera    `json:"camera,omitempty"`
+	CapturedAt  *time.Time `json:"captured_at,omitempty"`
+	Orientation int        `json:"orientation,omitempty"`
+	Software    string     `json:"software,omitempty"`
+	Artist      string     `json:"artist,omitempty"`
+	Copyright   string     `json:"copyright,omitempty"`
+	Width       int        `json:"width,omitempty"`
+	Height      int        `json:"height,omitempty"`
+	GPS         *GPS       `json:"gps,omitempty"`
+	IPTC        *IPTC      `json:"iptc,omitempty"`
+}
+
+type Camera struct {
+	Make         string  `json:"make,omitempty"`
+	Model        string  `json:"model,omitempty"`
+	LensModel    string  `json:"lens_model,omitempty"`
+	ExposureTime string  `json:"exposure_time,omitempty"`
+	FNumber      float64 `json:"f_number,omitempty"`
+	ISO          int     `json:"iso,omitempty"`
+	FocalLength  float64 `json:"focal_length,omitempty"`
+}
+
+type GPS struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Altitude  *float64 `json:"altitude,omitempty"`
+}
+
+type IPTC struct {
+	Title     string   `json:"title,omitempty"`
+	Headline  string   `json:"headline,omitempty"`
+	Caption   string   `json:"caption,omitempty"`
+	Keywords  []string `json:"keywords,omitempty"`
+	Creator   string   `json:"creator,omitempty"`
+	Copyright string   `json:"copyright,omitempty"`
+	City      string   `json:"city,omitempty"`
+	Country   string   `json:"country,omitempty"`
+}
+
+func (p Policy) Valid() bool {
+	switch p {
+	case PolicyKeep, PolicyStripGPS, PolicyStripAll:
+		return true
+	}
+	return false
+}
+
+func (md *Metadata) IsEmpty() bool {
+	return md == nil || (md.Camera == nil && md.CapturedAt == nil && md.Orientation == 0 &&
+		md.Software == "" && md.Artist == "" && md.Copyright == "" &&
+		md.Width == 0 && md.Height == 0 && md.GPS == nil && md.IPTC == nil)
+}
+
+// Extract reads the EXIF and IPTC segments of a JPEG stream. Only the header
+// segments are consumed, the compressed image data is never read.
+func Extract(r io.Reader) (*Metadata, error) {
+	md := &Metadata{}
+
+	err := readSegments(bufio.NewReader(r), func(marker byte, data []byte) error {
+		switch {
+		case marker == markerAPP1 && bytes.HasPrefix(data, exifHeader):
+			if err := parseExif(data[len(exifHeader):], md); err != nil {
+				return fmt.Errorf("failed to parse exif: %w", err)
+			}
+		case marker == markerAPP13 && bytes.HasPrefix(data, photoshopHeader):
+			iptc := parseIPTC(data[len(photoshopHeader):])
+			if iptc != nil {
+				md.IPTC = iptc
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return md, nil
+}
+
+// Strip copies a JPEG stream to w, removing metadata according to the policy.
+func Strip(w io.Writer, r io.Reader, policy Policy) error {
+	if policy == "" || policy == PolicyKeep {
+		_, err := io.Copy(w, r)
+		return err
+	}
+
+	if !policy.Valid() {
+		return fmt.Errorf("unknown metadata policy: %s", policy)
+	}
+
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+
+	if _, err := bw.Write([]byte{0xff, markerSOI}); err != nil {
+		return err
+	}
+
+	orientation := 0
+	orientationWritten := false
+
+	err := readSegments(br, func(marker byte, data []byte) error {
+		if marker == markerAPP1 && bytes.HasPrefix(data, exifHeader) {
+			if policy == PolicyStripAll {
+				md := &Metadata{}
+				if err := parseExif(data[len(exifHeader):], md); err == nil {
+					orientation = md.Orientation
+				}
+				return nil
+			}
+
+			stripped := append([]byte(nil), data...)
+			if err := stripGPS(stripped[len(exifHeader):]); err != nil {
+				return fmt.Errorf("failed to strip gps: %w", err)
+			}
+			return writeSegment(bw, marker, stripped)
+		}
+
+		if policy == PolicyStripAll && isMetadataSegment(marker) {
+			return nil
+		}
+
+		if policy == PolicyStripGPS && marker == markerAPP1 && bytes.HasPrefix(data, xmpHeader) && bytes.Contains(data, []byte("GPS")) {
+			return nil
+		}
+
+		// Keep the image upright once the EXIF block is gone by writing a
+		// minimal one that only carries the orientation.
+		if policy == PolicyStripAll && !orientationWritten && orientation > 1 && marker != markerAPP0 {
+			orientationWritten = true
+			if err := writeSegment(bw, markerAPP1, orientationExif(orientation)); err != nil {
+				return err
+			}
+		}
+
+		return writeSegment(bw, marker, data)
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(bw, br); err != nil {
+		return fmt.Errorf("failed to copy image data: %w", err)
+	}
+
+	return bw.Flush()
+}
+
+func isMetadataSegment(marker byte) bool {
+	switch marker {
+	case markerAPP1, markerAPP12, markerAPP13, markerCOM:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,471 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"io"
+	"testing"
+)
+
+// byteOrder reads and appends integers, as binary.BigEndian and binary.LittleEndian do
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+type testEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+func asciiEntry(tag uint16, s string) testEntry {
+	return testEntry{tag: tag, typ: typeASCII, count: uint32(len(s) + 1), value: append([]byte(s), 0)}
+}
+
+func shortEntry(order byteOrder, tag uint16, v uint16) testEntry {
+	return testEntry{tag: tag, typ: typeShort, count: 1, value: order.AppendUint16(nil, v)}
+}
+
+func rationalEntry(order byteOrder, tag uint16, values ...[2]uint32) testEntry {
+	var value []byte
+	for _, v := range values {
+		value = order.AppendUint32(value, v[0])
+		value = order.AppendUint32(value, v[1])
+	}
+	return testEntry{tag: tag, typ: typeRational, count: uint32(len(values)), value: value}
+}
+
+func ifdSize(entries []testEntry) int {
+	size := 2 + len(entries)*12 + 4
+	for _, e := range entries {
+		if len(e.value) > 4 {
+			size += len(e.value)
+		}
+	}
+	return size
+}
+
+// appendIFD writes an IFD at the end of data, followed by the values that do not fit in an entry
+func appendIFD(order byteOrder, data []byte, entries []testEntry) []byte {
+	valuePos := len(data) + 2 + len(entries)*12 + 4
+	var values []byte
+
+	data = order.AppendUint16(data, uint16(len(entries)))
+	for _, e := range entries {
+		data = order.AppendUint16(data, e.tag)
+		data = order.AppendUint16(data, e.typ)
+		data = order.AppendUint32(data, e.count)
+		if len(e.value) <= 4 {
+			data = append(data, e.value...)
+			data = append(data, make([]byte, 4-len(e.value))...)
+		} else {
+			data = order.AppendUint32(data, uint32(valuePos+len(values)))
+			values = append(values, e.value...)
+		}
+	}
+	data = order.AppendUint32(data, 0)
+
+	return append(data, values...)
+}
+
+// buildTIFF lays out IFD0 followed by the GPS IFD it points to, when there is one
+func buildTIFF(order byteOrder, ifd0, gps []testEntry) []byte {
+	data := []byte("II")
+	if order == binary.BigEndian {
+		data = []byte("MM")
+	}
+	data = order.AppendUint16(data, 42)
+	data = order.AppendUint32(data, 8)
+
+	if gps != nil {
+		ifd0 = append(ifd0, testEntry{tag: tagGPSIFD, typ: typeLong, count: 1})
+		offset := 8 + ifdSize(ifd0)
+		ifd0[len(ifd0)-1].value = order.AppendUint32(nil, uint32(offset))
+	}
+
+	data = appendIFD(order, data, ifd0)
+	if gps != nil {
+		data = appendIFD(order, data, gps)
+	}
+	return data
+}
+
+func segment(marker byte, data []byte) []byte {
+	return append([]byte{0xff, marker, byte((len(data) + 2) >> 8), byte(len(data) + 2)}, data...)
+}
+
+// testJPEG returns a small encoded image with the segments inserted after SOI
+func testJPEG(t testing.TB, segments ...[]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	out := append([]byte(nil), data[:2]...)
+	for _, s := range segments {
+		out = append(out, s...)
+	}
+	return append(out, data[2:]...)
+}
+
+func exifSegment(tiff []byte) []byte {
+	return segment(markerAPP1, append(append([]byte(nil), exifHeader...), tiff...))
+}
+
+func gpsEntries(order byteOrder) []testEntry {
+	return []testEntry{
+		asciiEntry(tagGPSLatitudeRef, "N"),
+		rationalEntry(order, tagGPSLatitude, [2]uint32{52, 1}, [2]uint32{30, 1}, [2]uint32{0, 1}),
+		asciiEntry(tagGPSLongitudeRef, "W"),
+		rationalEntry(order, tagGPSLongitude, [2]uint32{13, 1}, [2]uint32{24, 1}, [2]uint32{0, 1}),
+		{tag: tagGPSAltitudeRef, typ: typeByte, count: 1, value: []byte{1}},
+		rationalEntry(order, tagGPSAltitude, [2]uint32{34, 1}),
+	}
+}
+
+func cameraEntries(order byteOrder) []testEntry {
+	return []testEntry{
+		asciiEntry(tagMake, "Canon"),
+		asciiEntry(tagModel, "EOS 5D"),
+		shortEntry(order, tagOrientation, 6),
+		asciiEntry(tagDateTime, "2024:05:01 10:20:30"),
+	}
+}
+
+func TestExtract(t *testing.T) {
+	for _, order := range []byteOrder{binary.BigEndian, binary.LittleEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			md, err := Extract(bytes.NewReader(testJPEG(t, exifSegment(buildTIFF(order, cameraEntries(order), gpsEntries(order))))))
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+
+			if md.Camera == nil || md.Camera.Make != "Canon" || md.Camera.Model != "EOS 5D" {
+				t.Errorf("Camera = %+v, want Canon EOS 5D", md.Camera)
+			}
+			if md.Orientation != 6 {
+				t.Errorf("Orientation = %d, want 6", md.Orientation)
+			}
+			if md.CapturedAt == nil || md.CapturedAt.Format("2006-01-02 15:04:05") != "2024-05-01 10:20:30" {
+				t.Errorf("CapturedAt = %v, want 2024-05-01 10:20:30", md.CapturedAt)
+			}
+			if md.GPS == nil {
+				t.Fatal("GPS = nil, want coordinates")
+			}
+			if md.GPS.Latitude != 52.5 || md.GPS.Longitude != -13.4 {
+				t.Errorf("GPS = %v, %v, want 52.5, -13.4", md.GPS.Latitude, md.GPS.Longitude)
+			}
+			if md.GPS.Altitude == nil || *md.GPS.Altitude != -34 {
+				t.Errorf("Altitude = %v, want -34", md.GPS.Altitude)
+			}
+		})
+	}
+}
+
+func TestExtractIPTC(t *testing.T) {
+	iim := []byte{0x1c, 2, 5, 0, 5}
+	iim = append(iim, "Title"...)
+	iim = append(iim, 0x1c, 2, 25, 0, 3)
+	iim = append(iim, "sea"...)
+
+	resource := append([]byte("8BIM"), 0x04, 0x04, 0, 0)
+	resource = binary.BigEndian.AppendUint32(resource, uint32(len(iim)))
+	resource = append(resource, iim...)
+
+	app13 := segment(markerAPP13, append(append([]byte(nil), photoshopHeader...), resource...))
+
+	md, err := Extract(bytes.NewReader(testJPEG(t, app13)))
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if md.IPTC == nil || md.IPTC.Title != "Title" || len(md.IPTC.Keywords) != 1 || md.IPTC.Keywords[0] != "sea" {
+		t.Errorf("IPTC = %+v, want title and keyword", md.IPTC)
+	}
+}
+
+func TestExtractMalformed(t *testing.T) {
+	order := binary.BigEndian
+	valid := buildTIFF(order, cameraEntries(order), gpsEntries(order))
+
+	// patch returns a copy of the valid TIFF with bytes replaced at an offset
+	patch := func(offset int, b ...byte) []byte {
+		data := append([]byte(nil), valid...)
+		copy(data[offset:], b)
+		return data
+	}
+
+	// The first IFD0 entry holds the offset of the Make string at byte 8+2+8
+	makeValueOffset := 8 + 2 + 8
+
+	// The GPS pointer is the last IFD0 entry
+	gpsPointer := 8 + 2 + len(cameraEntries(order))*12 + 8
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+		anyErr  bool
+		check   func(t *testing.T, md *Metadata)
+	}{
+		{
+			name:    "empty input",
+			data:    nil,
+			wantErr: ErrNotJPEG,
+		},
+		{
+			name:    "not a JPEG",
+			data:    []byte("GIF89a"),
+			wantErr: ErrNotJPEG,
+		},
+		{
+			name:   "truncated segment",
+			data:   testJPEG(t, exifSegment(valid))[:40],
+			anyErr: true,
+		},
+		{
+			name:   "segment length below two",
+			data:   []byte{0xff, markerSOI, 0xff, markerAPP1, 0, 1},
+			anyErr: true,
+		},
+		{
+			name:   "invalid byte order",
+			data:   testJPEG(t, exifSegment(patch(0, 'X', 'X'))),
+			anyErr: true,
+		},
+		{
+			name:   "truncated TIFF header",
+			data:   testJPEG(t, exifSegment([]byte("MM\x00"))),
+			anyErr: true,
+		},
+		{
+			name:   "IFD0 offset out of range",
+			data:   testJPEG(t, exifSegment(patch(4, 0x7f, 0xff, 0xff, 0xff))),
+			anyErr: true,
+		},
+		{
+			name:   "IFD0 entry count out of range",
+			data:   testJPEG(t, exifSegment(patch(8, 0xff, 0xff))),
+			anyErr: true,
+		},
+		{
+			name: "value offset out of range",
+			data: testJPEG(t, exifSegment(patch(makeValueOffset, 0xff, 0xff, 0xff, 0xf0))),
+			check: func(t *testing.T, md *Metadata) {
+				if md.Camera == nil || md.Camera.Make != "" || md.Camera.Model != "EOS 5D" {
+					t.Errorf("Camera = %+v, want only the model", md.Camera)
+				}
+			},
+		},
+		{
+			name: "value count out of range",
+			data: testJPEG(t, exifSegment(patch(8+2+4, 0x7f, 0xff, 0xff, 0xff))),
+			check: func(t *testing.T, md *Metadata) {
+				if md.Camera == nil || md.Camera.Make != "" {
+					t.Errorf("Camera = %+v, want no make", md.Camera)
+				}
+			},
+		},
+		{
+			name: "GPS IFD offset out of range",
+			data: testJPEG(t, exifSegment(patch(gpsPointer, 0xff, 0xff, 0xff, 0xff))),
+			check: func(t *testing.T, md *Metadata) {
+				if md.GPS != nil {
+					t.Errorf("GPS = %+v, want nil", md.GPS)
+				}
+			},
+		},
+		{
+			name: "truncated IPTC resource",
+			data: testJPEG(t, segment(markerAPP13, append(append([]byte(nil), photoshopHeader...), "8BIM\x04\x04\x00\x00\xff\xff\xff\xff"...))),
+			check: func(t *testing.T, md *Metadata) {
+				if md.IPTC != nil {
+					t.Errorf("IPTC = %+v, want nil", md.IPTC)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md, err := Extract(bytes.NewReader(tt.data))
+
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Extract() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.anyErr:
+				if err == nil {
+					t.Fatal("Extract() error = nil, want an error")
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Extract() error = %v", err)
+				}
+				tt.check(t, md)
+			}
+		})
+	}
+}
+
+func TestStripGPS(t *testing.T) {
+	order := binary.LittleEndian
+	gps := gpsEntries(order)
+	latitude := gps[1].value
+
+	tests := []struct {
+		name string
+		tiff []byte
+	}{
+		{
+			name: "GPS IFD",
+			tiff: buildTIFF(order, cameraEntries(order), gps),
+		},
+		{
+			name: "GPS entry of an unknown type",
+			tiff: buildTIFF(order, cameraEntries(order), append(gps, testEntry{tag: 0x001d, typ: 0xff, count: 1, value: []byte("GPS!")})),
+		},
+		{
+			name: "duplicate GPS pointers",
+			tiff: func() []byte {
+				ifd0 := append(cameraEntries(order), testEntry{tag: tagGPSIFD, typ: typeLong, count: 1, value: order.AppendUint32(nil, 0)})
+				data := buildTIFF(order, ifd0, gps)
+				// Point the first GPS entry at a second copy of the GPS IFD
+				first := 8 + 2 + len(cameraEntries(order))*12 + 8
+				order.PutUint32(data[first:], uint32(len(data)))
+				return appendIFD(order, data, gps)
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := testJPEG(t, exifSegment(tt.tiff))
+
+			before, err := Extract(bytes.NewReader(input))
+			if err != nil || before.GPS == nil {
+				t.Fatalf("Extract() before stripping = %+v, %v, want GPS", before, err)
+			}
+
+			var out bytes.Buffer
+			if err := Strip(&out, bytes.NewReader(input), PolicyStripGPS); err != nil {
+				t.Fatalf("Strip() error = %v", err)
+			}
+
+			after, err := Extract(bytes.NewReader(out.Bytes()))
+			if err != nil {
+				t.Fatalf("Extract() after stripping error = %v", err)
+			}
+			if after.GPS != nil {
+				t.Errorf("GPS = %+v after stripping, want nil", after.GPS)
+			}
+			if bytes.Contains(out.Bytes(), latitude) {
+				t.Error("stripped image still contains the latitude")
+			}
+			if bytes.Contains(out.Bytes(), []byte("GPS!")) {
+				t.Error("stripped image still contains a GPS entry of an unknown type")
+			}
+			if after.Camera == nil || after.Camera.Make != "Canon" || after.Orientation != 6 {
+				t.Errorf("Metadata = %+v after stripping, want the camera and orientation kept", after)
+			}
+			if _, err := jpeg.Decode(bytes.NewReader(out.Bytes())); err != nil {
+				t.Errorf("stripped image does not decode: %v", err)
+			}
+		})
+	}
+}
+
+func TestStripGPSRemovesXMP(t *testing.T) {
+	xmp := segment(markerAPP1, append(append([]byte(nil), xmpHeader...), `<x:xmpmeta exif:GPSLatitude="52,30N"/>`...))
+
+	var out bytes.Buffer
+	if err := Strip(&out, bytes.NewReader(testJPEG(t, xmp)), PolicyStripGPS); err != nil {
+		t.Fatalf("Strip() error = %v", err)
+	}
+	if bytes.Contains(out.Bytes(), []byte("GPSLatitude")) {
+		t.Error("stripped image still contains the XMP GPS packet")
+	}
+}
+
+func TestStripAll(t *testing.T) {
+	order := binary.BigEndian
+	input := testJPEG(t,
+		exifSegment(buildTIFF(order, cameraEntries(order), gpsEntries(order))),
+		segment(markerCOM, []byte("comment")),
+	)
+
+	var out bytes.Buffer
+	if err := Strip(&out, bytes.NewReader(input), PolicyStripAll); err != nil {
+		t.Fatalf("Strip() error = %v", err)
+	}
+
+	md, err := Extract(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if md.Camera != nil || md.GPS != nil || md.CapturedAt != nil {
+		t.Errorf("Metadata = %+v, want only the orientation", md)
+	}
+	if md.Orientation != 6 {
+		t.Errorf("Orientation = %d, want 6", md.Orientation)
+	}
+	if bytes.Contains(out.Bytes(), []byte("comment")) {
+		t.Error("stripped image still contains the comment")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(out.Bytes())); err != nil {
+		t.Errorf("stripped image does not decode: %v", err)
+	}
+}
+
+func TestStripMalformed(t *testing.T) {
+	order := binary.BigEndian
+	valid := buildTIFF(order, cameraEntries(order), gpsEntries(order))
+	broken := append([]byte(nil), valid...)
+	copy(broken[8:], []byte{0xff, 0xff})
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "not a JPEG", data: []byte("GIF89a")},
+		{name: "truncated segment", data: testJPEG(t, exifSegment(valid))[:40]},
+		{name: "IFD0 entry count out of range", data: testJPEG(t, exifSegment(broken))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Strip(io.Discard, bytes.NewReader(tt.data), PolicyStripGPS); err == nil {
+				t.Error("Strip() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func FuzzExtract(f *testing.F) {
+	order := binary.BigEndian
+	f.Add(testJPEG(f))
+	f.Add(testJPEG(f, exifSegment(buildTIFF(order, cameraEntries(order), gpsEntries(order)))))
+	f.Add(testJPEG(f, exifSegment(buildTIFF(binary.LittleEndian, cameraEntries(binary.LittleEndian), gpsEntries(binary.LittleEndian)))))
+	f.Add(testJPEG(f, segment(markerAPP13, append(append([]byte(nil), photoshopHeader...), "8BIM\x04\x04\x00\x00\x00\x00\x00\x08\x1c\x02\x05\x00\x03abc"...))))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Extract(bytes.NewReader(data))
+
+		var out bytes.Buffer
+		if err := Strip(&out, bytes.NewReader(data), PolicyStripGPS); err == nil {
+			if md, err := Extract(bytes.NewReader(out.Bytes())); err == nil && md.GPS != nil {
+				t.Errorf("GPS = %+v after stripping", md.GPS)
+			}
+		}
+
+		Strip(io.Discard, bytes.NewReader(data), PolicyStripAll)
+	})
+}
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	ConversionStatusPending = "pending"
+	ConversionStatusDone    = "done"
+	ConversionStatusFailed  = "failed"
+)
+
+// ConversionResult describes a generated conversion or responsive image, or
+// the last attempt to generate it.
+type ConversionResult struct {
+	Status      string    `json:"status"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	Size        int64     `json:"size"`
+	Format      string    `json:"format"`
+	MimeType    string    `json:"mime_type"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Error       string    `json:"error,omitempty"`
+	Attempts    int       `json:"attempts"`
+}
+
+// Done reports whether the file was generated. Entries written before the
+// status was recorded only exist for generated files.
+func (r ConversionResult) Done() bool {
+	return r.Status == ConversionStatusDone || r.Status == ""
+}
+
+// GetGeneratedConversions returns the conversion entries by name, including
+// pending and failed ones. Entries stored by older versions as booleans are
+// returned without details.
+func (m *Media) GetGeneratedConversions() (map[string]ConversionResult, error) {
+	results := make(map[string]ConversionResult)
+	if isEmptyJSON(m.GeneratedConversions) {
+		return results, nil
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(m.GeneratedConversions, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal generated conversions: %w", err)
+	}
+
+	for name, value := range raw {
+		result, ok, err := decodeConversionResult(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid generated conversion %s: %w", name, err)
+		}
+		if ok {
+			results[name] = result
+		}
+	}
+
+	return results, nil
+}
+
+// GetGeneratedConversion returns the result of a single conversion and whether
+// it has been generated.
+func (m *Media) GetGeneratedConversion(name string) (ConversionResult, bool) {
+	results, err := m.GetGeneratedConversions()
+	if err != nil {
+		return ConversionResult{}, false
+	}
+
+	result, ok := results[name]
+	return result, ok && result.Done()
+}
+
+// SetGeneratedConversions stores the generated conversions on the media.
+func (m *Media) SetGeneratedConversions(results map[string]ConversionResult) error {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return fmt.Errorf("failed to marshal generated conversions: %w", err)
+	}
+
+	m.GeneratedConversions = data
+	return nil
+}
+
+// GetResponsiveImages returns the responsive image entries by conversion name
+// and width, including pending and failed ones. Both legacy formats, {"name":{"320":true}} and
+// {"name":{"widths":[320]}}, are read as well.
+func (m *Media) GetResponsiveImages() (map[string]map[int]ConversionResult, error) {
+	results := make(map[string]map[int]ConversionResult)
+	if isEmptyJSON(m.ResponsiveImages) {
+		return results, nil
+	}
+
+	var raw map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(m.ResponsiveImages, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal responsive images: %w", err)
+	}
+
+	for name, entries := range raw {
+		widths := make(map[int]ConversionResult, len(entries))
+
+		for key, value := range entries {
+			if key == "widths" {
+				var legacy []int
+				if err := json.Unmarshal(value, &legacy); err != nil {
+					return nil, fmt.Errorf("invalid responsive widths of %s: %w", name, err)
+				}
+				for _, width := range legacy {
+					widths[width] = ConversionResult{Width: width}
+				}
+				continue
+			}
+
+			width, err := strconv.Atoi(key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid responsive width %q of %s", key, name)
+			}
+
+			result, ok, err := decodeConversionResult(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid responsive image %s at width %d: %w", name, width, err)
+			}
+			if ok {
+				widths[width] = result
+			}
+		}
+
+		results[name] = widths
+	}
+
+	return results, nil
+}
+
+// GetResponsiveImage returns the result of a single responsive image and
+// whether it has been generated.
+func (m *Media) GetResponsiveImage(name string, width int) (ConversionResult, bool) {
+	results, err := m.GetResponsiveImages()
+	if err != nil {
+		return ConversionResult{}, false
+	}
+
+	result, ok := results[name][width]
+	return result, ok && result.Done()
+}
+
+// SetResponsiveImages stores the generated responsive images on the media.
+func (m *Media) SetResponsiveImages(results map[string]map[int]ConversionResult) error {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return fmt.Errorf("failed to marshal responsive images: %w", err)
+	}
+
+	m.ResponsiveImages = data
+	return nil
+}
+
+// decodeConversionResult decodes a stored entry, which is either a result
+// object or a legacy boolean. The second return value is false for entries
+// that were not generated.
+func decodeConversionResult(value json.RawMessage) (ConversionResult, bool, error) {
+	var generated bool
+	if err := json.Unmarshal(value, &generated); err == nil {
+		return ConversionResult{}, generated, nil
+	}
+
+	var result ConversionResult
+	if err := json.Unmarshal(value, &result); err != nil {
+		return ConversionResult{}, false, err
+	}
+	return result, true, nil
+}
+
+func isEmptyJSON(data json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null"))
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/gofrs/uuid"
+	"github.com/vortechron/go-medialibrary/storage"
 )
 
 
@@ -24,7 +25,23 @@ type Media struct {
 	CustomProperties     json.RawMessage `json:"custom_properties" gorm:"type:json"`
 	GeneratedConversions json.RawMessage `json:"generated_conversions" gorm:"type:json"`
 	ResponsiveImages     json.RawMessage `json:"responsive_images" gorm:"type:json"`
+	Metadata             json.RawMessage `json:"metadata" gorm:"type:json"`
+	FocalPoint           json.RawMessage `json:"focal_point" gorm:"type:json"`
+	Visibility           string          `json:"visibility" gorm:"type:varchar(16);default:public"`
 	OrderColumn          *int            `json:"order_column" gorm:"index"`
 	CreatedAt            time.Time       `json:"created_at"`
 	UpdatedAt            time.Time       `json:"updated_at"`
 }
+
+
+func (m *Media) GetVisibility() string {
+	if m.Visibility == "" {
+		return storage.VisibilityPublic
+	}
+	return m.Visibility
+}
+
+
+func (m *Media) IsPrivate() bool {
+	return m.GetVisibility() == storage.VisibilityPrivate
+}
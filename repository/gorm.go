@@ -66,6 +66,21 @@ func (r *GormMediaRepository) FindByID(ctx context.Context, id uint64) (*models.
 }
 
 
+func (r *GormMediaRepository) FindByUUID(ctx context.Context, uuid string) (*models.Media, error) {
+	var media models.Media
+
+	tx := r.db.WithContext(ctx)
+	if err := tx.Where("uuid = ?", uuid).First(&media).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find media by UUID: %w", err)
+	}
+
+	return &media, nil
+}
+
+
 func (r *GormMediaRepository) Delete(ctx context.Context, media *models.Media) error {
 	tx := r.db.WithContext(ctx)
 	if err := tx.Delete(media).Error; err != nil {
@@ -47,6 +47,9 @@ func (r *SQLMediaRepository) CreateTablesIfNotExist(ctx context.Context) error {
 		custom_properties JSON,
 		generated_conversions JSON,
 		responsive_images JSON,
+		metadata JSON,
+		focal_point JSON,
+		visibility VARCHAR(16) NOT NULL DEFAULT 'public',
 		order_column INT,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
@@ -61,6 +64,43 @@ func (r *SQLMediaRepository) CreateTablesIfNotExist(ctx context.Context) error {
 		return fmt.Errorf("failed to create media table: %w", err)
 	}
 
+	return r.addMissingColumns(ctx)
+}
+
+// addedColumns are the columns added after the media table was first released, with their
+// definitions. Tables created by earlier versions are migrated by addMissingColumns.
+var addedColumns = []struct {
+	name       string
+	definition string
+}{
+	{"metadata", "JSON"},
+	{"focal_point", "JSON"},
+	{"visibility", "VARCHAR(16) NOT NULL DEFAULT 'public'"},
+}
+
+// addMissingColumns adds the columns of addedColumns that an existing media table lacks.
+// MySQL 5.7 has no ADD COLUMN IF NOT EXISTS, so the columns are looked up first.
+func (r *SQLMediaRepository) addMissingColumns(ctx context.Context) error {
+	for _, column := range addedColumns {
+		var count int
+		err := r.db.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM information_schema.COLUMNS
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'media' AND COLUMN_NAME = ?
+		`, column.name).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("failed to check media column %s: %w", column.name, err)
+		}
+
+		if count > 0 {
+			continue
+		}
+
+		query := fmt.Sprintf("ALTER TABLE media ADD COLUMN %s %s", column.name, column.definition)
+		if _, err := r.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to add media column %s: %w", column.name, err)
+		}
+	}
+
 	return nil
 }
 
@@ -69,7 +109,7 @@ func scanMedia(row *sql.Row) (*models.Media, error) {
 	var media models.Media
 	var uuidStr string
 	var createdAt, updatedAt time.Time
-	var manipulations, customProperties, generatedConversions, responsiveImages []byte
+	var manipulations, customProperties, generatedConversions, responsiveImages, metadata, focalPoint []byte
 	var orderColumn sql.NullInt32
 
 	err := row.Scan(
@@ -88,6 +128,9 @@ func scanMedia(row *sql.Row) (*models.Media, error) {
 		&customProperties,
 		&generatedConversions,
 		&responsiveImages,
+		&metadata,
+		&focalPoint,
+		&media.Visibility,
 		&orderColumn,
 		&createdAt,
 		&updatedAt,
@@ -109,6 +152,8 @@ func scanMedia(row *sql.Row) (*models.Media, error) {
 	media.CustomProperties = json.RawMessage(customProperties)
 	media.GeneratedConversions = json.RawMessage(generatedConversions)
 	media.ResponsiveImages = json.RawMessage(responsiveImages)
+	media.Metadata = json.RawMessage(metadata)
+	media.FocalPoint = json.RawMessage(focalPoint)
 
 	// Handle nullable order column
 	if orderColumn.Valid {
@@ -130,7 +175,7 @@ func scanMediaList(rows *sql.Rows) ([]*models.Media, error) {
 		var media models.Media
 		var uuidStr string
 		var createdAt, updatedAt time.Time
-		var manipulations, customProperties, generatedConversions, responsiveImages []byte
+		var manipulations, customProperties, generatedConversions, responsiveImages, metadata, focalPoint []byte
 		var orderColumn sql.NullInt32
 
 		err := rows.Scan(
@@ -149,6 +194,9 @@ func scanMediaList(rows *sql.Rows) ([]*models.Media, error) {
 			&customProperties,
 			&generatedConversions,
 			&responsiveImages,
+			&metadata,
+			&focalPoint,
+			&media.Visibility,
 			&orderColumn,
 			&createdAt,
 			&updatedAt,
@@ -170,6 +218,8 @@ func scanMediaList(rows *sql.Rows) ([]*models.Media, error) {
 		media.CustomProperties = json.RawMessage(customProperties)
 		media.GeneratedConversions = json.RawMessage(generatedConversions)
 		media.ResponsiveImages = json.RawMessage(responsiveImages)
+		media.Metadata = json.RawMessage(metadata)
+		media.FocalPoint = json.RawMessage(focalPoint)
 
 		// Handle nullable order column
 		if orderColumn.Valid {
@@ -199,8 +249,8 @@ func (r *SQLMediaRepository) Save(ctx context.Context, media *models.Media) erro
 				model_type, model_id, uuid, collection_name, name, file_name, 
 				mime_type, disk, conversions_disk, size, manipulations, 
 				custom_properties, generated_conversions, responsive_images, 
-				order_column, created_at, updated_at
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				metadata, focal_point, visibility, order_column, created_at, updated_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		`
 
 		var orderColumnValue interface{} = nil
@@ -225,6 +275,9 @@ func (r *SQLMediaRepository) Save(ctx context.Context, media *models.Media) erro
 			media.CustomProperties,
 			media.GeneratedConversions,
 			media.ResponsiveImages,
+			media.Metadata,
+			media.FocalPoint,
+			media.GetVisibility(),
 			orderColumnValue,
 			media.CreatedAt,
 			media.UpdatedAt,
@@ -249,7 +302,7 @@ func (r *SQLMediaRepository) Save(ctx context.Context, media *models.Media) erro
 				name = ?, file_name = ?, mime_type = ?, disk = ?, 
 				conversions_disk = ?, size = ?, manipulations = ?, 
 				custom_properties = ?, generated_conversions = ?, 
-				responsive_images = ?, order_column = ?, updated_at = ?
+				responsive_images = ?, metadata = ?, focal_point = ?, visibility = ?, order_column = ?, updated_at = ?
 			WHERE id = ?
 		`
 
@@ -275,6 +328,9 @@ func (r *SQLMediaRepository) Save(ctx context.Context, media *models.Media) erro
 			media.CustomProperties,
 			media.GeneratedConversions,
 			media.ResponsiveImages,
+			media.Metadata,
+			media.FocalPoint,
+			media.GetVisibility(),
 			orderColumnValue,
 			time.Now(),
 			media.ID,
@@ -294,7 +350,7 @@ func (r *SQLMediaRepository) FindByID(ctx context.Context, id uint64) (*models.M
 		SELECT id, model_type, model_id, uuid, collection_name, name, 
 		       file_name, mime_type, disk, conversions_disk, size, 
 		       manipulations, custom_properties, generated_conversions, 
-		       responsive_images, order_column, created_at, updated_at
+		       responsive_images, metadata, focal_point, visibility, order_column, created_at, updated_at
 		FROM media
 		WHERE id = ?
 	`
@@ -312,6 +368,30 @@ func (r *SQLMediaRepository) FindByID(ctx context.Context, id uint64) (*models.M
 	return media, nil
 }
 
+// FindByUUID retrieves a media record by UUID
+func (r *SQLMediaRepository) FindByUUID(ctx context.Context, uuid string) (*models.Media, error) {
+	query := `
+		SELECT id, model_type, model_id, uuid, collection_name, name, 
+		       file_name, mime_type, disk, conversions_disk, size, 
+		       manipulations, custom_properties, generated_conversions, 
+		       responsive_images, metadata, focal_point, visibility, order_column, created_at, updated_at
+		FROM media
+		WHERE uuid = ?
+	`
+
+	row := r.db.QueryRowContext(ctx, query, uuid)
+
+	media, err := scanMedia(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find media by UUID: %w", err)
+	}
+
+	return media, nil
+}
+
 // Delete removes a media record
 func (r *SQLMediaRepository) Delete(ctx context.Context, media *models.Media) error {
 	query := `DELETE FROM media WHERE id = ?`
@@ -330,7 +410,7 @@ func (r *SQLMediaRepository) FindByModelTypeAndID(ctx context.Context, modelType
 		SELECT id, model_type, model_id, uuid, collection_name, name, 
 		       file_name, mime_type, disk, conversions_disk, size, 
 		       manipulations, custom_properties, generated_conversions, 
-		       responsive_images, order_column, created_at, updated_at
+		       responsive_images, metadata, focal_point, visibility, order_column, created_at, updated_at
 		FROM media
 		WHERE model_type = ? AND model_id = ?
 	`
@@ -350,7 +430,7 @@ func (r *SQLMediaRepository) FindByCollection(ctx context.Context, collection st
 		SELECT id, model_type, model_id, uuid, collection_name, name, 
 		       file_name, mime_type, disk, conversions_disk, size, 
 		       manipulations, custom_properties, generated_conversions, 
-		       responsive_images, order_column, created_at, updated_at
+		       responsive_images, metadata, focal_point, visibility, order_column, created_at, updated_at
 		FROM media
 		WHERE collection_name = ?
 	`
@@ -370,7 +450,7 @@ func (r *SQLMediaRepository) FindByModelAndCollection(ctx context.Context, model
 		SELECT id, model_type, model_id, uuid, collection_name, name, 
 		       file_name, mime_type, disk, conversions_disk, size, 
 		       manipulations, custom_properties, generated_conversions, 
-		       responsive_images, order_column, created_at, updated_at
+		       responsive_images, metadata, focal_point, visibility, order_column, created_at, updated_at
 		FROM media
 		WHERE model_type = ? AND model_id = ? AND collection_name = ?
 	`
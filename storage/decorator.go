@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Operation names a storage method, used by decorators and for injected failures
+type Operation string
+
+const (
+	OperationSave            Operation = "save"
+	OperationSaveFromURL     Operation = "save_from_url"
+	OperationGet             Operation = "get"
+	OperationGetRange        Operation = "get_range"
+	OperationExists          Operation = "exists"
+	OperationStat            Operation = "stat"
+	OperationDelete          Operation = "delete"
+	OperationList            Operation = "list"
+	OperationDeleteDirectory Operation = "delete_directory"
+	OperationCopy            Operation = "copy"
+	OperationMove            Operation = "move"
+	OperationTemporaryURL    Operation = "temporary_url"
+	OperationSetVisibility   Operation = "set_visibility"
+)
+
+// Decorator wraps a storage to add behavior around its calls, such as retries or logging.
+// Decorators are attached when registering a disk with DiskManager.AddDisk.
+type Decorator func(Storage) Storage
+
+// Unwrapper is implemented by decorated storages
+type Unwrapper interface {
+	Unwrap() Storage
+}
+
+// Unwrap returns the storage below all decorators of s, for example to reach the Handler
+// of a decorated LocalStorage
+func Unwrap(s Storage) Storage {
+	for {
+		u, ok := s.(Unwrapper)
+		if !ok {
+			return s
+		}
+		s = u.Unwrap()
+	}
+}
+
+// aroundFunc runs fn, the operation op on path of the wrapped storage, with the behavior of a decorator
+type aroundFunc func(ctx context.Context, op Operation, path string, fn func(context.Context) error) error
+
+// decorated implements Storage by running every call of the wrapped storage through around.
+// URL is passed through as it only builds a string.
+type decorated struct {
+	next   Storage
+	around aroundFunc
+}
+
+func decorate(next Storage, around aroundFunc) *decorated {
+	return &decorated{next: next, around: around}
+}
+
+func (d *decorated) Unwrap() Storage {
+	return d.next
+}
+
+func (d *decorated) Save(ctx context.Context, path string, contents io.Reader, options ...Option) error {
+	body := newReplayableBody(contents)
+	return d.around(ctx, OperationSave, path, func(ctx context.Context) error {
+		reader, err := body.reader()
+		if err != nil {
+			return err
+		}
+		return body.result(d.next.Save(ctx, path, reader, options...))
+	})
+}
+
+func (d *decorated) SaveFromURL(ctx context.Context, path string, url string, options ...Option) error {
+	return d.around(ctx, OperationSaveFromURL, path, func(ctx context.Context) error {
+		return d.next.SaveFromURL(ctx, path, url, options...)
+	})
+}
+
+func (d *decorated) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	err := d.around(ctx, OperationGet, path, func(ctx context.Context) error {
+		var err error
+		reader, err = d.next.Get(ctx, path)
+		return err
+	})
+	return reader, err
+}
+
+func (d *decorated) GetRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	err := d.around(ctx, OperationGetRange, path, func(ctx context.Context) error {
+		var err error
+		reader, err = d.next.GetRange(ctx, path, offset, length)
+		return err
+	})
+	return reader, err
+}
+
+func (d *decorated) Exists(ctx context.Context, path string) (bool, error) {
+	var exists bool
+	err := d.around(ctx, OperationExists, path, func(ctx context.Context) error {
+		var err error
+		exists, err = d.next.Exists(ctx, path)
+		return err
+	})
+	return exists, err
+}
+
+func (d *decorated) Stat(ctx context.Context, path string) (ObjectInfo, error) {
+	var info ObjectInfo
+	err := d.around(ctx, OperationStat, path, func(ctx context.Context) error {
+		var err error
+		info, err = d.next.Stat(ctx, path)
+		return err
+	})
+	return info, err
+}
+
+func (d *decorated) Delete(ctx context.Context, path string) error {
+	return d.around(ctx, OperationDelete, path, func(ctx context.Context) error {
+		return d.next.Delete(ctx, path)
+	})
+}
+
+func (d *decorated) URL(path string) string {
+	return d.next.URL(path)
+}
+
+func (d *decorated) TemporaryURL(ctx context.Context, path string, expiry int64) (string, error) {
+	var url string
+	err := d.around(ctx, OperationTemporaryURL, path, func(ctx context.Context) error {
+		var err error
+		url, err = d.next.TemporaryURL(ctx, path, expiry)
+		return err
+	})
+	return url, err
+}
+
+func (d *decorated) List(ctx context.Context, prefix string, recursive bool, options ...ListOption) (*ListPage, error) {
+	var page *ListPage
+	err := d.around(ctx, OperationList, prefix, func(ctx context.Context) error {
+		var err error
+		page, err = d.next.List(ctx, prefix, recursive, options...)
+		return err
+	})
+	return page, err
+}
+
+func (d *decorated) DeleteDirectory(ctx context.Context, prefix string) error {
+	return d.around(ctx, OperationDeleteDirectory, prefix, func(ctx context.Context) error {
+		return d.next.DeleteDirectory(ctx, prefix)
+	})
+}
+
+// SetVisibility returns an error wrapping errors.ErrUnsupported when the wrapped storage
+// cannot change visibility
+func (d *decorated) SetVisibility(ctx context.Context, path string, visibility string) error {
+	setter, ok := d.next.(VisibilitySetter)
+	if !ok {
+		return fmt.Errorf("changing visibility: %w", errors.ErrUnsupported)
+	}
+
+	return d.around(ctx, OperationSetVisibility, path, func(ctx context.Context) error {
+		return setter.SetVisibility(ctx, path, visibility)
+	})
+}
+
+func (d *decorated) Copy(ctx context.Context, src, dst string, options ...Option) error {
+	return d.around(ctx, OperationCopy, dst, func(ctx context.Context) error {
+		return Copy(ctx, d.next, src, d.next, dst, options...)
+	})
+}
+
+func (d *decorated) Move(ctx context.Context, src, dst string, options ...Option) error {
+	return d.around(ctx, OperationMove, dst, func(ctx context.Context) error {
+		return Move(ctx, d.next, src, d.next, dst, options...)
+	})
+}
+
+// decorators returns the decorators of s from the outermost inwards and the storage below them.
+// ok is false when s is wrapped by a decorator of another package, whose behavior cannot be
+// applied to a transfer done by the backend.
+func decorators(s Storage) (chain []aroundFunc, base Storage, ok bool) {
+	for {
+		switch d := s.(type) {
+		case *decorated:
+			chain = append(chain, d.around)
+			s = d.next
+		case Unwrapper:
+			return nil, nil, false
+		default:
+			return chain, s, true
+		}
+	}
+}
+
+// runAround runs fn through a chain of decorators, the first one outermost
+func runAround(ctx context.Context, chain []aroundFunc, op Operation, path string, fn func(context.Context) error) error {
+	for i := len(chain) - 1; i >= 0; i-- {
+		around, next := chain[i], fn
+		fn = func(ctx context.Context) error {
+			return around(ctx, op, path, next)
+		}
+	}
+	return fn(ctx)
+}
+
+// errNotReplayable stops retries of a save whose body was partly consumed and cannot be rewound
+type errNotReplayable struct {
+	err error
+}
+
+func (e *errNotReplayable) Error() string {
+	return e.err.Error()
+}
+
+func (e *errNotReplayable) Unwrap() error {
+	return e.err
+}
+
+// replayableBody lets a save be attempted again. Seekable bodies are rewound, other bodies
+// can only be sent again when the failed attempt did not read from them.
+type replayableBody struct {
+	contents io.Reader
+	seeker   io.Seeker
+	start    int64
+	read     int64
+	attempts int
+	lastErr  error
+}
+
+func newReplayableBody(contents io.Reader) *replayableBody {
+	body := &replayableBody{contents: contents}
+
+	if seeker, ok := contents.(io.Seeker); ok {
+		if start, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			body.seeker = seeker
+			body.start = start
+		}
+	}
+
+	return body
+}
+
+// reader returns the body for the next attempt
+func (b *replayableBody) reader() (io.Reader, error) {
+	b.attempts++
+	if b.attempts == 1 {
+		if b.seeker != nil {
+			return b.contents, nil
+		}
+		return b, nil
+	}
+
+	if b.seeker != nil {
+		if _, err := b.seeker.Seek(b.start, io.SeekStart); err != nil {
+			return nil, &errNotReplayable{err: b.lastErr}
+		}
+		return b.contents, nil
+	}
+
+	if b.read > 0 {
+		return nil, &errNotReplayable{err: b.lastErr}
+	}
+	return b, nil
+}
+
+func (b *replayableBody) result(err error) error {
+	b.lastErr = err
+	return err
+}
+
+func (b *replayableBody) Read(p []byte) (int, error) {
+	n, err := b.contents.Read(p)
+	b.read += int64(n)
+	return n, err
+}
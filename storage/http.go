@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ServeObject writes an object of the storage to the response. It answers HEAD requests,
+// conditional requests with 304 Not Modified and single byte ranges with 206 Partial Content,
+// reading only the requested range from the storage. A Content-Type set on the response
+// before calling is kept, otherwise the stored content type is used.
+func ServeObject(w http.ResponseWriter, r *http.Request, s Storage, path string) {
+	info, err := s.Stat(r.Context(), path)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if info.IsDir {
+		http.NotFound(w, r)
+		return
+	}
+
+	header := w.Header()
+	if header.Get("Content-Type") == "" && info.ContentType != "" {
+		header.Set("Content-Type", info.ContentType)
+	}
+	if info.ETag != "" {
+		header.Set("ETag", info.ETag)
+	}
+	if !info.LastModified.IsZero() {
+		header.Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
+	header.Set("Accept-Ranges", "bytes")
+
+	if notModified(r, info) {
+		header.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	offset, length := int64(0), info.Size
+	status := http.StatusOK
+
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" && rangeApplies(r, info) {
+		start, end, ok, err := parseRange(rangeHeader, info.Size)
+		if err != nil {
+			header.Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+			http.Error(w, http.StatusText(http.StatusRequestedRangeNotSatisfiable), http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		if ok {
+			offset, length = start, end-start+1
+			status = http.StatusPartialContent
+			header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, info.Size))
+		}
+	}
+
+	header.Set("Content-Length", strconv.FormatInt(length, 10))
+
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+
+	var reader io.ReadCloser
+	if status == http.StatusPartialContent {
+		reader, err = s.GetRange(r.Context(), path, offset, length)
+	} else {
+		reader, err = s.Get(r.Context(), path)
+	}
+	if err != nil {
+		header.Del("Content-Length")
+		header.Del("Content-Range")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+
+	w.WriteHeader(status)
+	io.CopyN(w, reader, length)
+}
+
+// notModified reports whether the client already has the current version of the object
+func notModified(r *http.Request, info ObjectInfo) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		return info.ETag != "" && etagMatches(match, info.ETag)
+	}
+
+	if since := r.Header.Get("If-Modified-Since"); since != "" && !info.LastModified.IsZero() {
+		t, err := http.ParseTime(since)
+		return err == nil && !info.LastModified.Truncate(1e9).After(t)
+	}
+
+	return false
+}
+
+// rangeApplies checks If-Range, a range of a changed object is answered with the full object
+func rangeApplies(r *http.Request, info ObjectInfo) bool {
+	ifRange := r.Header.Get("If-Range")
+	if ifRange == "" {
+		return true
+	}
+
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, `W/"`) {
+		return ifRange == info.ETag
+	}
+
+	t, err := http.ParseTime(ifRange)
+	return err == nil && !info.LastModified.IsZero() && !info.LastModified.Truncate(1e9).After(t)
+}
+
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRange parses a Range header into an inclusive byte range. ok is false for headers
+// that are ignored, such as multiple ranges, which are answered with the full object.
+func parseRange(header string, size int64) (start, end int64, ok bool, err error) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, nil
+	}
+
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, ErrInvalidRange
+	}
+
+	if first == "" {
+		// A suffix range: the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 || size == 0 {
+			return 0, 0, false, ErrInvalidRange
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true, nil
+	}
+
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false, ErrInvalidRange
+	}
+
+	end = size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, ErrInvalidRange
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	return start, end, true, nil
+}
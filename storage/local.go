@@ -2,19 +2,27 @@ package storage
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 )
 
 
 type LocalConfig struct {
 	BasePath string 
 	BaseURL  string 
+
+	// SigningKey signs the URLs returned by TemporaryURL, without it they are the permanent URL
+	SigningKey []byte
 }
 
 
@@ -46,6 +54,7 @@ func NewLocalStorage(config LocalConfig) (*LocalStorage, error) {
 
 
 func (s *LocalStorage) Save(ctx context.Context, path string, contents io.Reader, options ...Option) error {
+	opts := NewOptions(options...)
 	fullPath := filepath.Join(s.config.BasePath, path)
 
 
@@ -55,14 +64,34 @@ func (s *LocalStorage) Save(ctx context.Context, path string, contents io.Reader
 	}
 
 
-	file, err := os.Create(fullPath)
+	// Write next to the target and rename, so hard linked copies keep their contents
+	file, err := os.CreateTemp(dir, "."+filepath.Base(fullPath)+".*.tmp")
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
-	defer file.Close()
+	defer os.Remove(file.Name())
+
 
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), contents); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
 
-	if _, err := io.Copy(file, contents); err != nil {
+	if err := os.Chmod(file.Name(), localFileMode(opts.Visibility)); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	// The sidecar goes first, so the file is never reachable without its visibility
+	if err := writeSidecar(fullPath, newLocalMetadata(opts, `"`+hex.EncodeToString(hash.Sum(nil))+`"`)); err != nil {
+		return err
+	}
+
+	if err := os.Rename(file.Name(), fullPath); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
@@ -113,6 +142,40 @@ func (s *LocalStorage) Get(ctx context.Context, path string) (io.ReadCloser, err
 }
 
 
+func (s *LocalStorage) GetRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	fullPath := filepath.Join(s.config.BasePath, path)
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+
+	if offset < 0 || offset > info.Size() {
+		file.Close()
+		return nil, fmt.Errorf("%w: offset %d of %d bytes", ErrInvalidRange, offset, info.Size())
+	}
+
+	if length < 0 || offset+length > info.Size() {
+		length = info.Size() - offset
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(file, offset, length), file}, nil
+}
+
+
 func (s *LocalStorage) Exists(ctx context.Context, path string) (bool, error) {
 	fullPath := filepath.Join(s.config.BasePath, path)
 
@@ -140,10 +203,114 @@ func (s *LocalStorage) Delete(ctx context.Context, path string) error {
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
 
+	os.Remove(sidecarPath(fullPath))
+
+	return nil
+}
+
+
+func (s *LocalStorage) SetVisibility(ctx context.Context, path string, visibility string) error {
+	fullPath := filepath.Join(s.config.BasePath, path)
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	meta, err := readSidecar(fullPath)
+	if err != nil {
+		return err
+	}
+
+	meta.Visibility = visibility
+	if err := writeSidecar(fullPath, meta); err != nil {
+		return err
+	}
+
+	if info.Mode().Perm() == localFileMode(visibility) {
+		return nil
+	}
+
+
+	// The file may be hard linked by a copy, so it is replaced instead of changed in place
+	return rewriteLocalFile(fullPath, localFileMode(visibility))
+}
+
+
+func rewriteLocalFile(fullPath string, mode os.FileMode) error {
+	source, err := os.Open(fullPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer source.Close()
+
+	file, err := os.CreateTemp(filepath.Dir(fullPath), "."+filepath.Base(fullPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, source); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	if err := os.Chmod(file.Name(), mode); err != nil {
+		return fmt.Errorf("failed to change file permissions: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), fullPath); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
 	return nil
 }
 
 
+func (s *LocalStorage) Stat(ctx context.Context, path string) (ObjectInfo, error) {
+	fullPath := filepath.Join(s.config.BasePath, path)
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ObjectInfo{}, fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+		return ObjectInfo{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	if info.IsDir() {
+		return ObjectInfo{Path: path, LastModified: info.ModTime(), IsDir: true}, nil
+	}
+
+	meta, err := readSidecar(fullPath)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+
+	etag := meta.ETag
+	if etag == "" {
+		etag = fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+	}
+
+	return ObjectInfo{
+		Path:         path,
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+		ContentType:  meta.ContentType,
+		ETag:         etag,
+		Metadata:     meta.Metadata,
+	}, nil
+}
+
+
 func (s *LocalStorage) URL(path string) string {
 	if s.config.BaseURL == "" {
 		return "/" + path
@@ -154,6 +321,227 @@ func (s *LocalStorage) URL(path string) string {
 
 
 func (s *LocalStorage) TemporaryURL(ctx context.Context, path string, expiry int64) (string, error) {
+	if len(s.config.SigningKey) == 0 {
+		return s.URL(path), nil
+	}
+
+	expires := time.Now().Add(time.Duration(expiry) * time.Second).Unix()
+	return s.signedURL(path, expires), nil
+}
+
+
+func (s *LocalStorage) List(ctx context.Context, prefix string, recursive bool, options ...ListOption) (*ListPage, error) {
+	opts := NewListOptions(options...)
+	root := filepath.Join(s.config.BasePath, prefix)
+
+	info, err := os.Stat(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &ListPage{}, nil
+		}
+		return nil, fmt.Errorf("failed to list directory: %w", err)
+	}
+
+	if !info.IsDir() {
+		return nil, fmt.Errorf("failed to list directory: %s is not a directory", prefix)
+	}
 
-	return s.URL(path), nil
+	var objects []ObjectInfo
+	err = filepath.WalkDir(root, func(fullPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if fullPath == root {
+			return nil
+		}
+
+		if !entry.IsDir() && isInternalFile(entry.Name()) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(s.config.BasePath, fullPath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+
+		if entry.IsDir() {
+			if recursive {
+				return nil
+			}
+			objects = append(objects, ObjectInfo{Path: relPath, IsDir: true})
+			return filepath.SkipDir
+		}
+
+		entryInfo, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, ObjectInfo{
+			Path:         relPath,
+			Size:         entryInfo.Size(),
+			LastModified: entryInfo.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list directory: %w", err)
+	}
+
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Path < objects[j].Path
+	})
+
+	if opts.PageToken != "" {
+		start := sort.Search(len(objects), func(i int) bool {
+			return objects[i].Path > opts.PageToken
+		})
+		objects = objects[start:]
+	}
+
+	page := &ListPage{Objects: objects}
+	if len(objects) > opts.MaxKeys {
+		page.Objects = objects[:opts.MaxKeys]
+		page.NextPageToken = page.Objects[len(page.Objects)-1].Path
+	}
+
+	return page, nil
+}
+
+
+func (s *LocalStorage) DeleteDirectory(ctx context.Context, prefix string) error {
+	fullPath := filepath.Join(s.config.BasePath, prefix)
+
+
+	relPath, err := filepath.Rel(s.config.BasePath, fullPath)
+	if err != nil || relPath == "." || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("failed to delete directory: invalid prefix %q", prefix)
+	}
+
+	if err := os.RemoveAll(fullPath); err != nil {
+		return fmt.Errorf("failed to delete directory: %w", err)
+	}
+
+	return nil
+}
+
+
+func (s *LocalStorage) Copy(ctx context.Context, src, dst string, options ...Option) error {
+	return copyLocalFile(s.fullPath(src), s.fullPath(dst), NewOptions(options...))
+}
+
+
+func (s *LocalStorage) Move(ctx context.Context, src, dst string, options ...Option) error {
+	return moveLocalFile(s.fullPath(src), s.fullPath(dst), NewOptions(options...))
+}
+
+
+func (s *LocalStorage) fullPath(path string) string {
+	return filepath.Join(s.config.BasePath, path)
+}
+
+
+func copyLocalFile(src, dst string, opts *Options) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("file not found: %w", err)
+		}
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// The copy keeps the metadata of src, only the visibility of the options is applied
+	if err := copySidecar(src, dst, opts.Visibility); err != nil {
+		return err
+	}
+
+
+	// Save replaces files instead of writing into them, so a hard link is a safe copy.
+	// Links share their permissions, so a copy changing the visibility needs its own file.
+	os.Remove(dst)
+	if opts.Visibility == "" {
+		if err := os.Link(src, dst); err == nil {
+			return nil
+		}
+	}
+
+	source, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer source.Close()
+
+	mode := info.Mode().Perm()
+	if opts.Visibility != "" {
+		mode = localFileMode(opts.Visibility)
+	}
+
+	target, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	if err := target.Close(); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	return nil
+}
+
+
+func moveLocalFile(src, dst string, opts *Options) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("file not found: %w", err)
+		}
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+
+	// The file may be hard linked by a copy, so permissions are never changed in place
+	if opts.Visibility == "" || info.Mode().Perm() == localFileMode(opts.Visibility) {
+		if err := copySidecar(src, dst, opts.Visibility); err != nil {
+			return err
+		}
+
+		if err := os.Rename(src, dst); err == nil {
+			os.Remove(sidecarPath(src))
+			return nil
+		}
+	}
+
+
+	// Renaming fails across file systems
+	if err := copyLocalFile(src, dst, opts); err != nil {
+		return err
+	}
+
+	if err := os.Remove(src); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	os.Remove(sidecarPath(src))
+
+	return nil
 }
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"net/http"
+	"os"
+	"path"
+)
+
+// Handler serves the files of the local disk, replacing a web server in front of BaseURL.
+// Responses carry the saved content type, content disposition and cache control, an ETag and
+// Last-Modified, and support conditional and range requests. Files saved with private
+// visibility are refused with 403 Forbidden unless the request is a valid temporary URL.
+// Mount it at the path of BaseURL, using http.StripPrefix for anything but the root.
+func (s *LocalStorage) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		filePath := cleanObjectPath(r.URL.Path)
+		if filePath == "" || isInternalFile(path.Base(filePath)) {
+			http.NotFound(w, r)
+			return
+		}
+
+		meta, err := readSidecar(s.fullPath(filePath))
+		if err != nil {
+			if _, statErr := os.Stat(s.fullPath(filePath)); os.IsNotExist(statErr) {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		if meta.Visibility == VisibilityPrivate {
+			if err := s.VerifyTemporaryURL(filePath, r.URL.Query()); err != nil {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			meta.CacheControl = "private, no-store"
+		}
+
+		if meta.ContentType != "" {
+			w.Header().Set("Content-Type", meta.ContentType)
+		}
+		if meta.ContentDisposition != "" {
+			w.Header().Set("Content-Disposition", meta.ContentDisposition)
+		}
+		if meta.CacheControl != "" {
+			w.Header().Set("Cache-Control", meta.CacheControl)
+		}
+
+		ServeObject(w, r, s, filePath)
+	})
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localMetadata is stored in a hidden sidecar file next to every local object and keeps
+// the save options that the file system cannot record itself
+type localMetadata struct {
+	ContentType        string            `json:"content_type,omitempty"`
+	ContentDisposition string            `json:"content_disposition,omitempty"`
+	Visibility         string            `json:"visibility,omitempty"`
+	CacheControl       string            `json:"cache_control,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	ETag               string            `json:"etag,omitempty"`
+}
+
+const sidecarSuffix = ".meta"
+
+// localFileMode keeps private files unreadable for other users, such as a web server serving BasePath
+func localFileMode(visibility string) os.FileMode {
+	if visibility == VisibilityPrivate {
+		return 0600
+	}
+	return 0644
+}
+
+// sidecarPath returns the sidecar of a file, "dir/.name.meta" for "dir/name"
+func sidecarPath(fullPath string) string {
+	return filepath.Join(filepath.Dir(fullPath), "."+filepath.Base(fullPath)+sidecarSuffix)
+}
+
+// isInternalFile reports whether a file name belongs to a sidecar or an unfinished save
+func isInternalFile(name string) bool {
+	return strings.HasPrefix(name, ".") && (strings.HasSuffix(name, sidecarSuffix) || strings.HasSuffix(name, ".tmp"))
+}
+
+func newLocalMetadata(opts *Options, etag string) *localMetadata {
+	meta := &localMetadata{
+		ContentType:        opts.ContentType,
+		ContentDisposition: opts.ContentDisposition,
+		Visibility:         opts.Visibility,
+		CacheControl:       opts.CacheControl,
+		ETag:               etag,
+	}
+
+	if len(opts.Metadata) > 0 {
+		meta.Metadata = opts.Metadata
+	}
+
+	return meta
+}
+
+func writeSidecar(fullPath string, meta *localMetadata) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to marshal file metadata: %w", err)
+	}
+
+	if err := os.WriteFile(sidecarPath(fullPath), data, 0644); err != nil {
+		return fmt.Errorf("failed to write file metadata: %w", err)
+	}
+
+	return nil
+}
+
+// readSidecar returns the stored metadata of a file, files saved without one get
+// an empty record with the content type guessed from the extension. Such files are
+// private unless other users can read them, so a missing sidecar never exposes a file.
+func readSidecar(fullPath string) (*localMetadata, error) {
+	meta := &localMetadata{}
+
+	data, err := os.ReadFile(sidecarPath(fullPath))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read file metadata: %w", err)
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(data, meta); err != nil {
+			return nil, fmt.Errorf("failed to read file metadata: %w", err)
+		}
+	} else {
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat file: %w", err)
+		}
+		if info.Mode().Perm()&0004 == 0 {
+			meta.Visibility = VisibilityPrivate
+		}
+	}
+
+	if meta.ContentType == "" {
+		meta.ContentType = mime.TypeByExtension(filepath.Ext(fullPath))
+	}
+
+	return meta, nil
+}
+
+// copySidecar copies the sidecar of src to dst, or removes a stale one of dst. A non-empty
+// visibility replaces the copied one and always leaves a sidecar recording it.
+func copySidecar(src, dst, visibility string) error {
+	if visibility != "" {
+		meta, err := readSidecar(src)
+		if err != nil {
+			return err
+		}
+		meta.Visibility = visibility
+		return writeSidecar(dst, meta)
+	}
+
+	data, err := os.ReadFile(sidecarPath(src))
+	if os.IsNotExist(err) {
+		os.Remove(sidecarPath(dst))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read file metadata: %w", err)
+	}
+
+	if err := os.WriteFile(sidecarPath(dst), data, 0644); err != nil {
+		return fmt.Errorf("failed to write file metadata: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSignedStorage(t *testing.T, key string) *LocalStorage {
+	t.Helper()
+
+	s, err := NewLocalStorage(LocalConfig{
+		BasePath:   filepath.Join(t.TempDir(), "files"),
+		BaseURL:    "http://files.test/",
+		SigningKey: []byte(key),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// signedQuery returns the query of a temporary URL for the path
+func signedQuery(t *testing.T, s *LocalStorage, filePath string, expiry int64) url.Values {
+	t.Helper()
+
+	raw, err := s.TemporaryURL(context.Background(), filePath, expiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query()
+}
+
+func TestVerifyTemporaryURL(t *testing.T) {
+	s := newSignedStorage(t, "secret")
+
+	tests := []struct {
+		name    string
+		path    string
+		query   func() url.Values
+		wantErr error
+	}{
+		{
+			name:  "valid",
+			path:  "media/1/photo.jpg",
+			query: func() url.Values { return signedQuery(t, s, "media/1/photo.jpg", 60) },
+		},
+		{
+			name:  "equivalent path",
+			path:  "/media/./1/photo.jpg",
+			query: func() url.Values { return signedQuery(t, s, "media/1/photo.jpg", 60) },
+		},
+		{
+			name:    "tampered path",
+			path:    "media/2/photo.jpg",
+			query:   func() url.Values { return signedQuery(t, s, "media/1/photo.jpg", 60) },
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "tampered expiry",
+			path: "media/1/photo.jpg",
+			query: func() url.Values {
+				q := signedQuery(t, s, "media/1/photo.jpg", 60)
+				expires, _ := strconv.ParseInt(q.Get("expires"), 10, 64)
+				q.Set("expires", strconv.FormatInt(expires+3600, 10))
+				return q
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "expired",
+			path: "media/1/photo.jpg",
+			query: func() url.Values {
+				expires := time.Now().Add(-time.Minute).Unix()
+				return url.Values{
+					"expires":   {strconv.FormatInt(expires, 10)},
+					"signature": {s.signature("media/1/photo.jpg", expires)},
+				}
+			},
+			wantErr: ErrURLExpired,
+		},
+		{
+			name:    "wrong key",
+			path:    "media/1/photo.jpg",
+			query:   func() url.Values { return signedQuery(t, newSignedStorage(t, "other"), "media/1/photo.jpg", 60) },
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "missing signature",
+			path: "media/1/photo.jpg",
+			query: func() url.Values {
+				q := signedQuery(t, s, "media/1/photo.jpg", 60)
+				q.Del("signature")
+				return q
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "malformed expiry",
+			path: "media/1/photo.jpg",
+			query: func() url.Values {
+				q := signedQuery(t, s, "media/1/photo.jpg", 60)
+				q.Set("expires", "soon")
+				return q
+			},
+			wantErr: ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.VerifyTemporaryURL(tt.path, tt.query())
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("VerifyTemporaryURL() error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyTemporaryURL() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyTemporaryURLWithoutKey(t *testing.T) {
+	s := newSignedStorage(t, "")
+
+	query := url.Values{"expires": {strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)}, "signature": {""}}
+	if err := s.VerifyTemporaryURL("media/1/photo.jpg", query); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("VerifyTemporaryURL() error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestSignedHandler(t *testing.T) {
+	s := newSignedStorage(t, "secret")
+	ctx := context.Background()
+
+	if err := s.Save(ctx, "media/1/photo.jpg", strings.NewReader("photo"), WithVisibility(VisibilityPrivate)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file next to the base path that no request may reach
+	outside := filepath.Join(filepath.Dir(s.config.BasePath), "secret.txt")
+	if err := os.WriteFile(outside, []byte("outside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.SignedHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		rec := get("/media/1/photo.jpg?" + signedQuery(t, s, "media/1/photo.jpg", 60).Encode())
+		if rec.Code != http.StatusOK || rec.Body.String() != "photo" {
+			t.Fatalf("status = %d, body = %q, want 200 and the file", rec.Code, rec.Body.String())
+		}
+		if cc := rec.Header().Get("Cache-Control"); cc != "private, no-store" {
+			t.Errorf("Cache-Control = %q, want private, no-store", cc)
+		}
+	})
+
+	t.Run("tampered path", func(t *testing.T) {
+		rec := get("/media/2/photo.jpg?" + signedQuery(t, s, "media/1/photo.jpg", 60).Encode())
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("status = %d, want 403", rec.Code)
+		}
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		if rec := get("/media/1/photo.jpg"); rec.Code != http.StatusForbidden {
+			t.Fatalf("status = %d, want 403", rec.Code)
+		}
+	})
+
+	t.Run("sidecar", func(t *testing.T) {
+		rec := get("/media/1/.photo.jpg.meta?" + signedQuery(t, s, "media/1/.photo.jpg.meta", 60).Encode())
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("status = %d, want 404", rec.Code)
+		}
+	})
+
+	for _, traversal := range []string{"../secret.txt", "media/../../secret.txt"} {
+		t.Run("path traversal "+traversal, func(t *testing.T) {
+			// Even a signature for the traversal path must not reach outside the base path
+			req := httptest.NewRequest(http.MethodGet, "/placeholder?"+signedQuery(t, s, traversal, 60).Encode(), nil)
+			req.URL.Path = "/" + traversal
+
+			rec := httptest.NewRecorder()
+			s.SignedHandler().ServeHTTP(rec, req)
+
+			body, _ := io.ReadAll(rec.Body)
+			if strings.Contains(string(body), "outside") {
+				t.Fatalf("served a file outside the base path")
+			}
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want 404", rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Logging logs every call with its operation, path and duration. Successful calls are
+// logged at debug level and failed calls at error level, a nil logger uses slog.Default.
+// Use logger.With("disk", name) to tell disks apart.
+func Logging(logger *slog.Logger) Decorator {
+	return func(s Storage) Storage {
+		log := logger
+		if log == nil {
+			log = slog.Default()
+		}
+
+		return decorate(s, func(ctx context.Context, op Operation, path string, fn func(context.Context) error) error {
+			start := time.Now()
+			err := fn(ctx)
+
+			attrs := []slog.Attr{
+				slog.String("operation", string(op)),
+				slog.String("path", path),
+				slog.Duration("duration", time.Since(start)),
+			}
+
+			if err != nil {
+				attrs = append(attrs, slog.Any("error", err))
+				log.LogAttrs(ctx, slog.LevelError, "storage operation failed", attrs...)
+				return err
+			}
+
+			log.LogAttrs(ctx, slog.LevelDebug, "storage operation", attrs...)
+			return nil
+		})
+	}
+}
@@ -0,0 +1,453 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	pathpkg "path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStorage keeps objects in memory. It is safe for concurrent use and meant for tests:
+// URLs are deterministic, the options of every save are recorded and failures can be injected.
+type MemoryStorage struct {
+	baseURL  string
+	objects  map[string]*memoryObject
+	failures map[Operation]map[string]error
+	mu       sync.RWMutex
+}
+
+type memoryObject struct {
+	data     []byte
+	options  Options
+	modified time.Time
+	etag     string
+}
+
+// NewMemoryStorage creates an empty memory storage. URLs are baseURL followed by the path,
+// "memory://" is used when baseURL is empty.
+func NewMemoryStorage(baseURL string) *MemoryStorage {
+	if baseURL == "" {
+		baseURL = "memory://"
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+
+	return &MemoryStorage{
+		baseURL:  baseURL,
+		objects:  make(map[string]*memoryObject),
+		failures: make(map[Operation]map[string]error),
+	}
+}
+
+// FailOn makes every call of the operation return err until the failures are cleared
+func (s *MemoryStorage) FailOn(op Operation, err error) {
+	s.FailOnPath(op, "", err)
+}
+
+// FailOnPath makes calls of the operation for one path return err, an empty path matches all paths
+func (s *MemoryStorage) FailOnPath(op Operation, path string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures[op] == nil {
+		s.failures[op] = make(map[string]error)
+	}
+	s.failures[op][cleanObjectPath(path)] = err
+}
+
+// ClearFailures removes all injected failures
+func (s *MemoryStorage) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = make(map[Operation]map[string]error)
+}
+
+// SavedOptions returns a copy of the options the object was last saved with
+func (s *MemoryStorage) SavedOptions(path string) (Options, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	object, ok := s.objects[cleanObjectPath(path)]
+	if !ok {
+		return Options{}, false
+	}
+
+	options := object.options
+	options.Metadata = maps.Clone(options.Metadata)
+	return options, true
+}
+
+// Contents returns a copy of the stored bytes of an object
+func (s *MemoryStorage) Contents(path string) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	object, ok := s.objects[cleanObjectPath(path)]
+	if !ok {
+		return nil, false
+	}
+	return bytes.Clone(object.data), true
+}
+
+// Paths returns the paths of all stored objects in sorted order
+func (s *MemoryStorage) Paths() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	paths := make([]string, 0, len(s.objects))
+	for p := range s.objects {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+// Reset removes all objects and injected failures
+func (s *MemoryStorage) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects = make(map[string]*memoryObject)
+	s.failures = make(map[Operation]map[string]error)
+}
+
+func (s *MemoryStorage) Save(ctx context.Context, path string, contents io.Reader, options ...Option) error {
+	if err := s.failure(OperationSave, path); err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(contents)
+	if err != nil {
+		return fmt.Errorf("failed to read contents: %w", err)
+	}
+
+	s.put(path, data, NewOptions(options...))
+	return nil
+}
+
+func (s *MemoryStorage) SaveFromURL(ctx context.Context, path string, url string, options ...Option) error {
+	if err := s.failure(OperationSaveFromURL, path); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download file: status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+
+	opts := NewOptions(options...)
+	if opts.ContentType == "" {
+		opts.ContentType = resp.Header.Get("Content-Type")
+	}
+
+	s.put(path, data, opts)
+	return nil
+}
+
+func (s *MemoryStorage) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	if err := s.failure(OperationGet, path); err != nil {
+		return nil, err
+	}
+
+	object, err := s.object(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(object.data)), nil
+}
+
+func (s *MemoryStorage) GetRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	if err := s.failure(OperationGetRange, path); err != nil {
+		return nil, err
+	}
+
+	object, err := s.object(path)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(len(object.data))
+	if offset < 0 || offset > size {
+		return nil, fmt.Errorf("%w: offset %d of %d bytes", ErrInvalidRange, offset, size)
+	}
+
+	end := size
+	if length >= 0 && offset+length < size {
+		end = offset + length
+	}
+
+	return io.NopCloser(bytes.NewReader(object.data[offset:end])), nil
+}
+
+func (s *MemoryStorage) Exists(ctx context.Context, path string) (bool, error) {
+	if err := s.failure(OperationExists, path); err != nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.objects[cleanObjectPath(path)]
+	return ok, nil
+}
+
+func (s *MemoryStorage) Stat(ctx context.Context, path string) (ObjectInfo, error) {
+	if err := s.failure(OperationStat, path); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	object, err := s.object(path)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	contentType := object.options.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(pathpkg.Ext(path))
+	}
+
+	return ObjectInfo{
+		Path:         cleanObjectPath(path),
+		Size:         int64(len(object.data)),
+		LastModified: object.modified,
+		ContentType:  contentType,
+		ETag:         object.etag,
+		Metadata:     maps.Clone(object.options.Metadata),
+	}, nil
+}
+
+func (s *MemoryStorage) Delete(ctx context.Context, path string) error {
+	if err := s.failure(OperationDelete, path); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.objects, cleanObjectPath(path))
+	return nil
+}
+
+func (s *MemoryStorage) SetVisibility(ctx context.Context, path string, visibility string) error {
+	if err := s.failure(OperationSetVisibility, path); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	object, ok := s.objects[cleanObjectPath(path)]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+
+	updated := *object
+	updated.options.Visibility = visibility
+	s.objects[cleanObjectPath(path)] = &updated
+
+	return nil
+}
+
+func (s *MemoryStorage) URL(path string) string {
+	return s.baseURL + cleanObjectPath(path)
+}
+
+// TemporaryURL returns the URL with the expiry appended, so it does not depend on the clock
+func (s *MemoryStorage) TemporaryURL(ctx context.Context, path string, expiry int64) (string, error) {
+	if err := s.failure(OperationTemporaryURL, path); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s?expires=%d", s.URL(path), expiry), nil
+}
+
+func (s *MemoryStorage) List(ctx context.Context, prefix string, recursive bool, options ...ListOption) (*ListPage, error) {
+	if err := s.failure(OperationList, prefix); err != nil {
+		return nil, err
+	}
+
+	opts := NewListOptions(options...)
+	prefix = directoryPrefix(cleanObjectPath(prefix))
+
+	s.mu.RLock()
+	seen := make(map[string]bool)
+	var objects []ObjectInfo
+	for p, object := range s.objects {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+
+		if !recursive {
+			if dir, _, nested := strings.Cut(strings.TrimPrefix(p, prefix), "/"); nested {
+				if !seen[dir] {
+					seen[dir] = true
+					objects = append(objects, ObjectInfo{Path: prefix + dir, IsDir: true})
+				}
+				continue
+			}
+		}
+
+		objects = append(objects, ObjectInfo{
+			Path:         p,
+			Size:         int64(len(object.data)),
+			LastModified: object.modified,
+		})
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Path < objects[j].Path
+	})
+
+	if opts.PageToken != "" {
+		start := sort.Search(len(objects), func(i int) bool {
+			return objects[i].Path > opts.PageToken
+		})
+		objects = objects[start:]
+	}
+
+	page := &ListPage{Objects: objects}
+	if len(objects) > opts.MaxKeys {
+		page.Objects = objects[:opts.MaxKeys]
+		page.NextPageToken = page.Objects[len(page.Objects)-1].Path
+	}
+
+	return page, nil
+}
+
+func (s *MemoryStorage) DeleteDirectory(ctx context.Context, prefix string) error {
+	if err := s.failure(OperationDeleteDirectory, prefix); err != nil {
+		return err
+	}
+
+	prefix = directoryPrefix(cleanObjectPath(prefix))
+	if prefix == "" {
+		return fmt.Errorf("failed to delete directory: invalid prefix %q", prefix)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for p := range s.objects {
+		if strings.HasPrefix(p, prefix) {
+			delete(s.objects, p)
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) Copy(ctx context.Context, src, dst string, options ...Option) error {
+	if err := s.failure(OperationCopy, src); err != nil {
+		return err
+	}
+
+	object, err := s.object(src)
+	if err != nil {
+		return err
+	}
+
+	// Like S3 the copy keeps its metadata and only takes the visibility from the options
+	opts := object.options
+	if visibility := NewOptions(options...).Visibility; visibility != "" {
+		opts.Visibility = visibility
+	}
+
+	s.put(dst, object.data, &opts)
+	return nil
+}
+
+func (s *MemoryStorage) Move(ctx context.Context, src, dst string, options ...Option) error {
+	if err := s.failure(OperationMove, src); err != nil {
+		return err
+	}
+
+	object, err := s.object(src)
+	if err != nil {
+		return err
+	}
+
+	moved := *object
+	if visibility := NewOptions(options...).Visibility; visibility != "" {
+		moved.options.Visibility = visibility
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.objects, cleanObjectPath(src))
+	s.objects[cleanObjectPath(dst)] = &moved
+
+	return nil
+}
+
+// failure returns the injected error for the operation and path, if any
+func (s *MemoryStorage) failure(op Operation, path string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	failures := s.failures[op]
+	if err, ok := failures[cleanObjectPath(path)]; ok {
+		return err
+	}
+	return failures[""]
+}
+
+func (s *MemoryStorage) object(path string) (*memoryObject, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	object, ok := s.objects[cleanObjectPath(path)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	return object, nil
+}
+
+// put stores a copy of the data, stored objects are never modified so readers need no lock
+func (s *MemoryStorage) put(path string, data []byte, opts *Options) {
+	sum := md5.Sum(data)
+
+	object := &memoryObject{
+		data:     bytes.Clone(data),
+		options:  *opts,
+		modified: time.Now(),
+		etag:     `"` + hex.EncodeToString(sum[:]) + `"`,
+	}
+
+	object.options.Metadata = maps.Clone(opts.Metadata)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[cleanObjectPath(path)] = object
+}
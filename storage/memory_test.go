@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMemoryStorageMetadataIsCopied(t *testing.T) {
+	s := NewMemoryStorage("")
+	ctx := context.Background()
+
+	metadata := map[string]string{"owner": "alice"}
+	if err := s.Save(ctx, "a.txt", strings.NewReader("a"), WithMetadata(metadata)); err != nil {
+		t.Fatal(err)
+	}
+	metadata["owner"] = "changed by the caller"
+
+	info, err := s.Stat(ctx, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info.Metadata["owner"] = "changed through Stat"
+
+	options, ok := s.SavedOptions("a.txt")
+	if !ok {
+		t.Fatal("SavedOptions() found no object")
+	}
+	options.Metadata["owner"] = "changed through SavedOptions"
+
+	info, err = s.Stat(ctx, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Metadata["owner"]; got != "alice" {
+		t.Errorf("Metadata[owner] = %q, want alice", got)
+	}
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MetricsRecorder receives the outcome of every call of a storage wrapped by Metrics, for
+// example to update Prometheus or OpenTelemetry instruments
+type MetricsRecorder interface {
+	ObserveOperation(op Operation, duration time.Duration, err error)
+}
+
+// Metrics reports the duration and outcome of every call to the recorder
+func Metrics(recorder MetricsRecorder) Decorator {
+	return func(s Storage) Storage {
+		return decorate(s, func(ctx context.Context, op Operation, path string, fn func(context.Context) error) error {
+			start := time.Now()
+			err := fn(ctx)
+			recorder.ObserveOperation(op, time.Since(start), err)
+			return err
+		})
+	}
+}
+
+// DefaultLatencyBuckets are the upper bounds of the latency histograms of OperationMetrics
+var DefaultLatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// OperationStats are the counters and latency histogram of one operation
+type OperationStats struct {
+	Count         int64
+	Errors        int64
+	TotalDuration time.Duration
+	// Buckets are the upper bounds of the histogram, BucketCounts[i] counts the calls that
+	// took at most Buckets[i] and the last element of BucketCounts the slower ones
+	Buckets      []time.Duration
+	BucketCounts []int64
+}
+
+// OperationMetrics is a MetricsRecorder keeping operation counters and latency histograms
+// in memory. It is safe for concurrent use.
+type OperationMetrics struct {
+	buckets []time.Duration
+	stats   map[Operation]*OperationStats
+	mu      sync.Mutex
+}
+
+// NewOperationMetrics creates an empty recorder with the given ascending histogram bounds,
+// DefaultLatencyBuckets when none are given
+func NewOperationMetrics(buckets ...time.Duration) *OperationMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+
+	return &OperationMetrics{
+		buckets: append([]time.Duration(nil), buckets...),
+		stats:   make(map[Operation]*OperationStats),
+	}
+}
+
+// ObserveOperation implements MetricsRecorder
+func (m *OperationMetrics) ObserveOperation(op Operation, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.stats[op]
+	if !ok {
+		stats = &OperationStats{
+			Buckets:      m.buckets,
+			BucketCounts: make([]int64, len(m.buckets)+1),
+		}
+		m.stats[op] = stats
+	}
+
+	stats.Count++
+	if err != nil {
+		stats.Errors++
+	}
+	stats.TotalDuration += duration
+
+	bucket := len(m.buckets)
+	for i, bound := range m.buckets {
+		if duration <= bound {
+			bucket = i
+			break
+		}
+	}
+	stats.BucketCounts[bucket]++
+}
+
+// Snapshot returns a copy of the statistics of every operation called so far
+func (m *OperationMetrics) Snapshot() map[Operation]OperationStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[Operation]OperationStats, len(m.stats))
+	for op, stats := range m.stats {
+		copied := *stats
+		copied.BucketCounts = append([]int64(nil), stats.BucketCounts...)
+		snapshot[op] = copied
+	}
+
+	return snapshot
+}
+
+// Reset clears all statistics
+func (m *OperationMetrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats = make(map[Operation]*OperationStats)
+}
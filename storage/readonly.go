@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrReadOnly is returned for writes to a disk wrapped by ReadOnly
+var ErrReadOnly = errors.New("storage is read-only")
+
+// ReadOnly refuses every call that changes the storage with ErrReadOnly. Reads, URLs and
+// temporary URLs are passed through.
+func ReadOnly() Decorator {
+	return func(s Storage) Storage {
+		return decorate(s, func(ctx context.Context, op Operation, path string, fn func(context.Context) error) error {
+			switch op {
+			case OperationSave, OperationSaveFromURL, OperationDelete, OperationDeleteDirectory,
+				OperationCopy, OperationMove, OperationSetVisibility:
+				return ErrReadOnly
+			}
+			return fn(ctx)
+		})
+	}
+}
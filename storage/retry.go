@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// RetryConfig configures the Retry decorator, zero values use the defaults
+type RetryConfig struct {
+	// MaxAttempts is the number of tries including the first one, 3 by default
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, 100ms by default. It doubles with
+	// every retry and a random jitter of up to half the wait is subtracted.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between two tries, 5s by default
+	MaxBackoff time.Duration
+	// Retryable reports whether a failed try is repeated, IsRetryable by default
+	Retryable func(error) bool
+}
+
+// Retry repeats failed calls with exponential backoff and jitter. Every operation except
+// Move is idempotent and retried. A Save is only repeated when its body can be sent again:
+// seekable bodies are rewound, other bodies only when the failed try did not read from them.
+func Retry(config RetryConfig) Decorator {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = 100 * time.Millisecond
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 5 * time.Second
+	}
+	if config.Retryable == nil {
+		config.Retryable = IsRetryable
+	}
+
+	return func(s Storage) Storage {
+		return decorate(s, func(ctx context.Context, op Operation, path string, fn func(context.Context) error) error {
+			if op == OperationMove {
+				return fn(ctx)
+			}
+
+			backoff := config.InitialBackoff
+			for attempt := 1; ; attempt++ {
+				err := fn(ctx)
+				if err == nil {
+					return nil
+				}
+
+				var notReplayable *errNotReplayable
+				if errors.As(err, &notReplayable) {
+					return notReplayable.err
+				}
+
+				if attempt >= config.MaxAttempts || !config.Retryable(err) {
+					return err
+				}
+
+				wait := backoff - rand.N(backoff/2+1)
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return err
+				case <-timer.C:
+				}
+
+				backoff = min(backoff*2, config.MaxBackoff)
+			}
+		})
+	}
+}
+
+// throttlingCodes are S3 client errors that succeed when repeated later
+var throttlingCodes = map[string]bool{
+	"SlowDown":             true,
+	"Throttling":           true,
+	"ThrottlingException":  true,
+	"TooManyRequests":      true,
+	"RequestTimeout":       true,
+	"RequestTimeTooSkewed": true,
+}
+
+// IsRetryable reports whether an error may be transient. Canceled contexts, missing objects,
+// invalid requests, read-only disks, permission errors and S3 client errors other than
+// throttling are not retried.
+func IsRetryable(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, ErrNotFound),
+		errors.Is(err, ErrInvalidRange),
+		errors.Is(err, ErrReadOnly),
+		errors.Is(err, ErrInvalidSignature),
+		errors.Is(err, ErrURLExpired),
+		errors.Is(err, errors.ErrUnsupported),
+		errors.Is(err, fs.ErrNotExist),
+		errors.Is(err, fs.ErrExist),
+		errors.Is(err, fs.ErrPermission):
+		return false
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorFault() != smithy.FaultClient || throttlingCodes[apiErr.ErrorCode()]
+	}
+
+	return true
+}
@@ -6,21 +6,39 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// Visibility modes of S3Config
+const (
+	// S3VisibilityACL applies visibility as canned object ACLs
+	S3VisibilityACL = "acl"
+	// S3VisibilityPolicy tags objects with "visibility" for buckets with ACLs disabled, where
+	// a bucket policy grants public read on objects tagged "visibility=public"
+	S3VisibilityPolicy = "policy"
 )
 
 type S3Storage struct {
-	client     *s3.Client
-	bucket     string
-	region     string
-	baseURL    string
-	publicURLs bool
+	client         *s3.Client
+	uploader       *manager.Uploader
+	bucket         string
+	region         string
+	endpoint       string
+	usePathStyle   bool
+	baseURL        string
+	publicURLs     bool
+	visibilityMode string
 }
 
 type S3Config struct {
@@ -30,6 +48,17 @@ type S3Config struct {
 	PublicURLs bool
 	AccessKey  string
 	SecretKey  string
+
+	// Endpoint points the client at an S3-compatible store such as MinIO, e.g. "http://localhost:9000"
+	Endpoint string
+	// UsePathStyle addresses objects as endpoint/bucket/key instead of bucket.endpoint/key
+	UsePathStyle bool
+	// PartSize is the size of multipart upload parts in bytes, at least 5 MiB. Defaults to 5 MiB.
+	PartSize int64
+	// UploadConcurrency is the number of parts uploaded in parallel per object. Defaults to 5.
+	UploadConcurrency int
+	// VisibilityMode is S3VisibilityACL, the default, or S3VisibilityPolicy
+	VisibilityMode string
 }
 
 func NewS3Storage(ctx context.Context, cfg S3Config) (*S3Storage, error) {
@@ -58,14 +87,44 @@ func NewS3Storage(ctx context.Context, cfg S3Config) (*S3Storage, error) {
 		return nil, fmt.Errorf("unable to load AWS SDK config: %w", err)
 	}
 
-	client := s3.NewFromConfig(awsCfg)
+	if cfg.VisibilityMode == "" {
+		cfg.VisibilityMode = S3VisibilityACL
+	}
+	if cfg.VisibilityMode != S3VisibilityACL && cfg.VisibilityMode != S3VisibilityPolicy {
+		return nil, fmt.Errorf("unknown visibility mode %q", cfg.VisibilityMode)
+	}
+
+	if cfg.PartSize != 0 && cfg.PartSize < manager.MinUploadPartSize {
+		return nil, fmt.Errorf("part size must be at least %d bytes", manager.MinUploadPartSize)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
+
+	// The upload manager streams bodies in parts, so Save does not need a seekable reader
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		if cfg.PartSize > 0 {
+			u.PartSize = cfg.PartSize
+		}
+		if cfg.UploadConcurrency > 0 {
+			u.Concurrency = cfg.UploadConcurrency
+		}
+	})
 
 	storage := &S3Storage{
-		client:     client,
-		bucket:     cfg.Bucket,
-		region:     cfg.Region,
-		baseURL:    cfg.BaseURL,
-		publicURLs: cfg.PublicURLs,
+		client:         client,
+		uploader:       uploader,
+		bucket:         cfg.Bucket,
+		region:         cfg.Region,
+		endpoint:       strings.TrimSuffix(cfg.Endpoint, "/"),
+		usePathStyle:   cfg.UsePathStyle,
+		baseURL:        cfg.BaseURL,
+		publicURLs:     cfg.PublicURLs,
+		visibilityMode: cfg.VisibilityMode,
 	}
 
 	return storage, nil
@@ -96,11 +155,15 @@ func (s *S3Storage) Save(ctx context.Context, path string, contents io.Reader, o
 		putParams.Metadata = opts.Metadata
 	}
 
-	if opts.Visibility == "public" {
-		putParams.ACL = types.ObjectCannedACLPublicRead
+	if opts.Visibility != "" {
+		if s.visibilityMode == S3VisibilityPolicy {
+			putParams.Tagging = aws.String(visibilityTagging(opts.Visibility))
+		} else {
+			putParams.ACL = visibilityACL(opts.Visibility)
+		}
 	}
 
-	_, err := s.client.PutObject(ctx, putParams)
+	_, err := s.uploader.Upload(ctx, putParams)
 	if err != nil {
 		return fmt.Errorf("failed to upload file to S3: %w", err)
 	}
@@ -160,6 +223,36 @@ func (s *S3Storage) Get(ctx context.Context, path string) (io.ReadCloser, error)
 	return result.Body, nil
 }
 
+// GetRange reads length bytes starting at offset, a negative length reads to the end
+func (s *S3Storage) GetRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("%w: offset %d", ErrInvalidRange, offset)
+	}
+
+	byteRange := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		if length == 0 {
+			return io.NopCloser(strings.NewReader("")), nil
+		}
+		byteRange = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(path),
+		Range:  aws.String(byteRange),
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange" {
+			return nil, fmt.Errorf("%w: %s of %s", ErrInvalidRange, byteRange, path)
+		}
+		return nil, fmt.Errorf("failed to get object range from S3: %w", err)
+	}
+
+	return result.Body, nil
+}
+
 func (s *S3Storage) Exists(ctx context.Context, path string) (bool, error) {
 	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(s.bucket),
@@ -178,6 +271,56 @@ func (s *S3Storage) Exists(ctx context.Context, path string) (bool, error) {
 	return true, nil
 }
 
+func (s *S3Storage) Stat(ctx context.Context, path string) (ObjectInfo, error) {
+	result, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(path),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return ObjectInfo{}, fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+		return ObjectInfo{}, fmt.Errorf("failed to get object metadata from S3: %w", err)
+	}
+
+	return ObjectInfo{
+		Path:         path,
+		Size:         aws.ToInt64(result.ContentLength),
+		LastModified: aws.ToTime(result.LastModified),
+		ContentType:  aws.ToString(result.ContentType),
+		ETag:         aws.ToString(result.ETag),
+		Metadata:     result.Metadata,
+	}, nil
+}
+
+// SetVisibility changes the ACL of an object, or replaces its tags in policy mode
+func (s *S3Storage) SetVisibility(ctx context.Context, path string, visibility string) error {
+	var err error
+
+	if s.visibilityMode == S3VisibilityPolicy {
+		_, err = s.client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(path),
+			Tagging: &types.Tagging{
+				TagSet: []types.Tag{{Key: aws.String("visibility"), Value: aws.String(visibility)}},
+			},
+		})
+	} else {
+		_, err = s.client.PutObjectAcl(ctx, &s3.PutObjectAclInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(path),
+			ACL:    visibilityACL(visibility),
+		})
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to set visibility of object in S3: %w", err)
+	}
+
+	return nil
+}
+
 func (s *S3Storage) Delete(ctx context.Context, path string) error {
 	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.bucket),
@@ -198,6 +341,12 @@ func (s *S3Storage) URL(path string) string {
 	}
 
 	if s.publicURLs {
+		if s.endpoint != "" {
+			return s.endpointURL(path)
+		}
+		if s.usePathStyle {
+			return fmt.Sprintf("https://s3.%s.amazonaws.com/%s/%s", s.region, s.bucket, path)
+		}
 		return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, path)
 	}
 
@@ -219,3 +368,188 @@ func (s *S3Storage) TemporaryURL(ctx context.Context, path string, expiry int64)
 
 	return request.URL, nil
 }
+
+func (s *S3Storage) List(ctx context.Context, prefix string, recursive bool, options ...ListOption) (*ListPage, error) {
+	opts := NewListOptions(options...)
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		Prefix:  aws.String(directoryPrefix(prefix)),
+		MaxKeys: aws.Int32(int32(opts.MaxKeys)),
+	}
+
+	if !recursive {
+		input.Delimiter = aws.String("/")
+	}
+
+	if opts.PageToken != "" {
+		input.ContinuationToken = aws.String(opts.PageToken)
+	}
+
+	result, err := s.client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects in S3: %w", err)
+	}
+
+	page := &ListPage{}
+
+	for _, commonPrefix := range result.CommonPrefixes {
+		page.Objects = append(page.Objects, ObjectInfo{
+			Path:  strings.TrimSuffix(aws.ToString(commonPrefix.Prefix), "/"),
+			IsDir: true,
+		})
+	}
+
+	for _, object := range result.Contents {
+		page.Objects = append(page.Objects, ObjectInfo{
+			Path:         aws.ToString(object.Key),
+			Size:         aws.ToInt64(object.Size),
+			LastModified: aws.ToTime(object.LastModified),
+		})
+	}
+
+	sort.Slice(page.Objects, func(i, j int) bool {
+		return page.Objects[i].Path < page.Objects[j].Path
+	})
+
+	if aws.ToBool(result.IsTruncated) {
+		page.NextPageToken = aws.ToString(result.NextContinuationToken)
+	}
+
+	return page, nil
+}
+
+func (s *S3Storage) DeleteDirectory(ctx context.Context, prefix string) error {
+	prefix = directoryPrefix(prefix)
+	if prefix == "" || prefix == "/" {
+		return fmt.Errorf("failed to delete directory: invalid prefix %q", prefix)
+	}
+
+	// DeleteObjects accepts up to 1000 keys, the same as a single list page
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int32(1000),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list objects in S3: %w", err)
+		}
+
+		if len(page.Contents) == 0 {
+			continue
+		}
+
+		objects := make([]types.ObjectIdentifier, 0, len(page.Contents))
+		for _, object := range page.Contents {
+			objects = append(objects, types.ObjectIdentifier{Key: object.Key})
+		}
+
+		result, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &types.Delete{
+				Objects: objects,
+				Quiet:   aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete objects from S3: %w", err)
+		}
+
+		if len(result.Errors) > 0 {
+			failed := result.Errors[0]
+			return fmt.Errorf("failed to delete %d objects from S3, first %s: %s",
+				len(result.Errors), aws.ToString(failed.Key), aws.ToString(failed.Message))
+		}
+	}
+
+	return nil
+}
+
+// directoryPrefix makes sure a non-empty prefix ends with a slash so "1" does not match "10/"
+func directoryPrefix(prefix string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	return prefix + "/"
+}
+
+func (s *S3Storage) Copy(ctx context.Context, src, dst string, options ...Option) error {
+	return s.copyObject(ctx, s.bucket, src, dst, NewOptions(options...))
+}
+
+func (s *S3Storage) Move(ctx context.Context, src, dst string, options ...Option) error {
+	if err := s.copyObject(ctx, s.bucket, src, dst, NewOptions(options...)); err != nil {
+		return err
+	}
+
+	return s.Delete(ctx, src)
+}
+
+// copyObject copies a key of the source bucket into this bucket. The object keeps its
+// metadata, the ACL is not copied by S3 so the visibility is applied again.
+func (s *S3Storage) copyObject(ctx context.Context, srcBucket, src, dst string, opts *Options) error {
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(dst),
+		CopySource: aws.String(copySource(srcBucket, src)),
+	}
+
+	if opts.Visibility != "" {
+		if s.visibilityMode == S3VisibilityPolicy {
+			input.TaggingDirective = types.TaggingDirectiveReplace
+			input.Tagging = aws.String(visibilityTagging(opts.Visibility))
+		} else {
+			input.ACL = visibilityACL(opts.Visibility)
+		}
+	}
+
+	if _, err := s.client.CopyObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to copy object in S3: %w", err)
+	}
+
+	return nil
+}
+
+// sameEndpoint reports whether objects can be copied between both storages by S3
+func (s *S3Storage) sameEndpoint(other *S3Storage) bool {
+	return s.region == other.region && s.endpoint == other.endpoint
+}
+
+// endpointURL returns the public URL of an object on a custom endpoint
+func (s *S3Storage) endpointURL(path string) string {
+	if s.usePathStyle {
+		return fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucket, path)
+	}
+
+	endpoint, err := url.Parse(s.endpoint)
+	if err != nil || endpoint.Host == "" {
+		return fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucket, path)
+	}
+
+	endpoint.Host = s.bucket + "." + endpoint.Host
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(endpoint.String(), "/"), path)
+}
+
+func visibilityACL(visibility string) types.ObjectCannedACL {
+	if visibility == VisibilityPublic {
+		return types.ObjectCannedACLPublicRead
+	}
+	return types.ObjectCannedACLPrivate
+}
+
+func visibilityTagging(visibility string) string {
+	return url.Values{"visibility": {visibility}}.Encode()
+}
+
+// copySource returns the URL encoded bucket and key for CopyObject
+func copySource(bucket, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return bucket + "/" + strings.Join(segments, "/")
+}
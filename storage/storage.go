@@ -2,12 +2,26 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
+	"time"
 )
 
 
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
+
+var ErrNotFound = errors.New("object not found")
+
+
+var ErrInvalidRange = errors.New("invalid range")
+
+
 type Storage interface {
 
 	Save(ctx context.Context, path string, contents io.Reader, options ...Option) error
@@ -19,9 +33,15 @@ type Storage interface {
 	Get(ctx context.Context, path string) (io.ReadCloser, error)
 
 
+	GetRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error)
+
+
 	Exists(ctx context.Context, path string) (bool, error)
 
 
+	Stat(ctx context.Context, path string) (ObjectInfo, error)
+
+
 	Delete(ctx context.Context, path string) error
 
 
@@ -29,6 +49,12 @@ type Storage interface {
 
 
 	TemporaryURL(ctx context.Context, path string, expiry int64) (string, error)
+
+
+	List(ctx context.Context, prefix string, recursive bool, options ...ListOption) (*ListPage, error)
+
+
+	DeleteDirectory(ctx context.Context, prefix string) error
 }
 
 
@@ -45,7 +71,11 @@ func NewDiskManager() *DiskManager {
 }
 
 
-func (dm *DiskManager) AddDisk(name string, storage Storage) {
+func (dm *DiskManager) AddDisk(name string, storage Storage, decorators ...Decorator) {
+	for _, decorator := range decorators {
+		storage = decorator(storage)
+	}
+
 	dm.mu.Lock()
 	defer dm.mu.Unlock()
 	dm.disks[name] = storage
@@ -140,3 +170,65 @@ func NewOptions(opts ...Option) *Options {
 
 	return options
 }
+
+
+type ObjectInfo struct {
+	Path         string
+	Size         int64
+	LastModified time.Time
+	IsDir        bool
+	ContentType  string
+	ETag         string
+	Metadata     map[string]string
+}
+
+
+type ListPage struct {
+	Objects       []ObjectInfo
+	NextPageToken string
+}
+
+
+type ListOption func(*ListOptions)
+
+
+type ListOptions struct {
+	PageToken string
+	MaxKeys   int
+}
+
+
+func WithPageToken(token string) ListOption {
+	return func(o *ListOptions) {
+		o.PageToken = token
+	}
+}
+
+
+func WithMaxKeys(maxKeys int) ListOption {
+	return func(o *ListOptions) {
+		o.MaxKeys = maxKeys
+	}
+}
+
+
+func NewListOptions(opts ...ListOption) *ListOptions {
+	options := &ListOptions{
+		MaxKeys: 1000,
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if options.MaxKeys <= 0 {
+		options.MaxKeys = 1000
+	}
+
+	return options
+}
+
+
+type VisibilitySetter interface {
+	SetVisibility(ctx context.Context, path string, visibility string) error
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Copier is implemented by storages that can copy an object without downloading it
+type Copier interface {
+	Copy(ctx context.Context, src, dst string, options ...Option) error
+}
+
+// Mover is implemented by storages that can move an object without downloading it
+type Mover interface {
+	Move(ctx context.Context, src, dst string, options ...Option) error
+}
+
+// Copy copies src on the source storage to dst on the target storage. Copies within one
+// storage use its Copier, copies between two local or two S3 storages are done by the
+// backend when possible, anything else is streamed through Get and Save with the given options.
+// A backend copy between decorated storages runs through the decorators of the target.
+func Copy(ctx context.Context, source Storage, src string, target Storage, dst string, options ...Option) error {
+	if source == target {
+		if copier, ok := source.(Copier); ok {
+			return copier.Copy(ctx, src, dst, options...)
+		}
+	}
+
+	_, s, sourceOK := decorators(source)
+	targetChain, t, targetOK := decorators(target)
+
+	if sourceOK && targetOK {
+		if transfer := backendCopy(s, src, t, dst, options); transfer != nil {
+			err := runAround(ctx, targetChain, OperationCopy, dst, transfer)
+			if !errors.Is(err, errBackendUnavailable) {
+				return err
+			}
+		}
+	}
+
+	return stream(ctx, source, src, target, dst, options...)
+}
+
+// Move moves src on the source storage to dst on the target storage, using the backend
+// the same way Copy does and falling back to streaming followed by a delete. A backend
+// move runs through the decorators of the source and then those of the target.
+func Move(ctx context.Context, source Storage, src string, target Storage, dst string, options ...Option) error {
+	if source == target {
+		if mover, ok := source.(Mover); ok {
+			return mover.Move(ctx, src, dst, options...)
+		}
+	}
+
+	sourceChain, s, sourceOK := decorators(source)
+	targetChain, t, targetOK := decorators(target)
+
+	if sourceOK && targetOK {
+		if transfer := backendMove(s, src, t, dst, options); transfer != nil {
+			err := runAround(ctx, sourceChain, OperationMove, src, func(ctx context.Context) error {
+				return runAround(ctx, targetChain, OperationMove, dst, transfer)
+			})
+			if !errors.Is(err, errBackendUnavailable) {
+				return err
+			}
+		}
+	}
+
+	if err := stream(ctx, source, src, target, dst, options...); err != nil {
+		return err
+	}
+
+	if err := source.Delete(ctx, src); err != nil {
+		return fmt.Errorf("failed to delete source file: %w", err)
+	}
+
+	return nil
+}
+
+// errBackendUnavailable makes Copy and Move fall back to streaming
+var errBackendUnavailable = errors.New("backend transfer unavailable")
+
+// backendCopy returns the copy done by the backend of two undecorated storages, nil when
+// they cannot copy between each other
+func backendCopy(source Storage, src string, target Storage, dst string, options []Option) func(context.Context) error {
+	switch s := source.(type) {
+	case *LocalStorage:
+		if t, ok := target.(*LocalStorage); ok {
+			return func(ctx context.Context) error {
+				return copyLocalFile(s.fullPath(src), t.fullPath(dst), NewOptions(options...))
+			}
+		}
+	case *S3Storage:
+		// The target credentials may not be able to read the source bucket
+		if t, ok := target.(*S3Storage); ok && s.sameEndpoint(t) {
+			return func(ctx context.Context) error {
+				if err := t.copyObject(ctx, s.bucket, src, dst, NewOptions(options...)); err != nil {
+					return fmt.Errorf("%w: %w", errBackendUnavailable, err)
+				}
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// backendMove returns the move done by the backend of two undecorated storages, nil when
+// they cannot move between each other
+func backendMove(source Storage, src string, target Storage, dst string, options []Option) func(context.Context) error {
+	switch s := source.(type) {
+	case *LocalStorage:
+		if t, ok := target.(*LocalStorage); ok {
+			return func(ctx context.Context) error {
+				return moveLocalFile(s.fullPath(src), t.fullPath(dst), NewOptions(options...))
+			}
+		}
+	case *S3Storage:
+		if t, ok := target.(*S3Storage); ok && s.sameEndpoint(t) {
+			return func(ctx context.Context) error {
+				if err := t.copyObject(ctx, s.bucket, src, dst, NewOptions(options...)); err != nil {
+					return fmt.Errorf("%w: %w", errBackendUnavailable, err)
+				}
+				return s.Delete(ctx, src)
+			}
+		}
+	}
+	return nil
+}
+
+// stream copies an object by reading it from the source and writing it to the target
+func stream(ctx context.Context, source Storage, src string, target Storage, dst string, options ...Option) error {
+	reader, err := source.Get(ctx, src)
+	if err != nil {
+		return fmt.Errorf("failed to read source file: %w", err)
+	}
+	defer reader.Close()
+
+	if err := target.Save(ctx, dst, reader, options...); err != nil {
+		return fmt.Errorf("failed to write target file: %w", err)
+	}
+
+	return nil
+}